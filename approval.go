@@ -0,0 +1,95 @@
+package llm_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+)
+
+// ErrApprovalPending is returned by an Approver that cannot decide now, e.g. while a human
+// has not answered yet. The run stops with StopPaused and can be continued with ResumeTools.
+var ErrApprovalPending = errors.New("approval pending")
+
+// ApprovalDecision is the answer of an Approver.
+type ApprovalDecision struct {
+	Approved  bool
+	Reason    string // sent to the model when the call is rejected
+	Arguments string // replaces the arguments of an approved call when set
+}
+
+// Approver decides on a call to a tool requiring approval.
+type Approver func(ctx context.Context, call FunctionCall, tool llm_models.Tool) (ApprovalDecision, error)
+
+// Approve approves a call as is.
+func Approve() ApprovalDecision {
+	return ApprovalDecision{Approved: true}
+}
+
+// ApproveWithArguments approves a call replacing its JSON arguments.
+func ApproveWithArguments(arguments string) ApprovalDecision {
+	return ApprovalDecision{Approved: true, Arguments: arguments}
+}
+
+// Reject rejects a call, reason is sent to the model.
+func Reject(reason string) ApprovalDecision {
+	return ApprovalDecision{Reason: reason}
+}
+
+// needsApproval reports whether a call with the given arguments must be approved.
+func needsApproval(tool *llm_models.Tool, arguments string) bool {
+	switch tool.Approval {
+	case llm_models.ApprovalAlways:
+		return true
+	case llm_models.ApprovalNever:
+		return false
+	}
+	if tool.ApprovalFunc == nil {
+		return false
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		args = nil
+	}
+	return tool.ApprovalFunc(args)
+}
+
+// approveCalls asks approver about every call needing approval. Rejected calls are answered,
+// approved calls get their edited arguments. It reports whether a decision is pending.
+func approveCalls(ctx context.Context, approver Approver, calls []*toolCall) (bool, error) {
+	paused := false
+	for _, c := range calls {
+		if c.done || !needsApproval(&c.tool, c.call.Arguments) {
+			continue
+		}
+		decision := Reject("this tool requires approval and no approver is configured")
+		if approver != nil {
+			var err error
+			decision, err = approver(ctx, *c.call, c.tool)
+			if errors.Is(err, ErrApprovalPending) {
+				paused = true
+				continue
+			}
+			if err != nil {
+				return false, fmt.Errorf("approve call of %s: %w", c.call.Name, err)
+			}
+		}
+		if !decision.Approved {
+			reason := decision.Reason
+			if reason == "" {
+				reason = "rejected"
+			}
+			data, _ := json.Marshal(toolError{Error: "call rejected: " + reason, Type: "rejected", Tool: c.call.Name})
+			c.result, c.rejected, c.done = string(data), true, true
+			continue
+		}
+		if decision.Arguments != "" {
+			edited := *c.call
+			edited.Arguments = decision.Arguments
+			c.call = &edited
+		}
+	}
+	return paused, nil
+}
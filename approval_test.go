@@ -0,0 +1,108 @@
+package llm_client
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func deleteTool(deleted *[]string) llm_models.Tool {
+	return llm_models.Tool{
+		Function: llm_models.FuncDef{Name: "delete", ParamOrder: []string{"env"}},
+		CallFunc: func(env string) string {
+			*deleted = append(*deleted, env)
+			return "deleted " + env
+		},
+		ApprovalFunc: func(args map[string]interface{}) bool {
+			return args == nil || args["env"] != "dev"
+		},
+	}
+}
+
+func deleteCall(id, env string) *FunctionCall {
+	return &FunctionCall{ID: id, Name: "delete", Arguments: `{"env":"` + env + `"}`}
+}
+
+func TestResolveChatApproval(t *testing.T) {
+	var deleted []string
+	var asked []string
+	approver := func(ctx context.Context, call FunctionCall, tool llm_models.Tool) (ApprovalDecision, error) {
+		asked = append(asked, call.ID)
+		if call.ID == "2" {
+			return Reject("production is off limits"), nil
+		}
+		return ApproveWithArguments(`{"env":"staging"}`), nil
+	}
+	client := &fakeClient{responses: []Response{
+		{FunctionCalls: []*FunctionCall{deleteCall("1", "dev"), deleteCall("2", "prod")}},
+		{FunctionCalls: []*FunctionCall{deleteCall("3", "prod")}},
+		{Content: "cleaned up staging"},
+	}}
+	res, err := RunTools(context.Background(), client, nil, []llm_models.Tool{deleteTool(&deleted)},
+		ToolLoopOptions{MaxCalls: 5, Approver: approver})
+	require.NoError(t, err)
+	assert.Equal(t, "cleaned up staging", res.Answer)
+	assert.Equal(t, []string{"2", "3"}, asked)
+	assert.Equal(t, []string{"dev", "staging"}, deleted)
+
+	var rejected toolError
+	require.NoError(t, json.Unmarshal([]byte(res.Messages[3].Content), &rejected))
+	assert.Equal(t, "rejected", rejected.Type)
+	assert.Contains(t, rejected.Error, "production is off limits")
+	// the transcript shows the edited arguments
+	assert.Equal(t, `{"env":"staging"}`, res.Messages[4].FunctionCall.Arguments)
+
+	// without an approver calls needing approval are rejected
+	deleted = nil
+	client = &fakeClient{responses: []Response{{FunctionCalls: []*FunctionCall{deleteCall("1", "prod")}}, {Content: "ok"}}}
+	tool := deleteTool(&deleted)
+	tool.Approval = llm_models.ApprovalAlways
+	res, err = RunTools(context.Background(), client, nil, []llm_models.Tool{tool}, ToolLoopOptions{MaxCalls: 5})
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+	require.NoError(t, json.Unmarshal([]byte(res.Steps[0].Output), &rejected))
+	assert.Contains(t, rejected.Error, "no approver")
+}
+
+func TestResolveChatApprovalPauseResume(t *testing.T) {
+	var deleted []string
+	decision := map[string]ApprovalDecision{}
+	approver := func(ctx context.Context, call FunctionCall, tool llm_models.Tool) (ApprovalDecision, error) {
+		d, ok := decision[call.ID]
+		if !ok {
+			return ApprovalDecision{}, ErrApprovalPending
+		}
+		return d, nil
+	}
+	tools := []llm_models.Tool{deleteTool(&deleted)}
+	opts := ToolLoopOptions{MaxCalls: 5, Approver: approver}
+	client := &fakeClient{responses: []Response{
+		{FunctionCalls: []*FunctionCall{deleteCall("1", "dev"), deleteCall("2", "prod")}},
+		{Content: "done"},
+	}}
+	paused, err := RunTools(context.Background(), client, []Message{{Role: RoleUser, Content: "clean up"}}, tools, opts)
+	require.NoError(t, err)
+	assert.Equal(t, StopPaused, paused.StopReason)
+	assert.Len(t, paused.Pending, 2)
+	assert.Equal(t, 0, paused.CallCount)
+	// nothing of the paused turn ran, not even the call without approval
+	assert.Empty(t, deleted)
+
+	_, err = ResumeTools(context.Background(), client, &RunResult{StopReason: StopFinalAnswer}, tools, opts)
+	assert.Error(t, err)
+
+	decision["2"] = Approve()
+	res, err := ResumeTools(context.Background(), client, paused, tools, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "done", res.Answer)
+	assert.Equal(t, []string{"dev", "prod"}, deleted)
+	assert.Equal(t, 2, res.CallCount)
+	assert.Len(t, res.Rounds, 2)
+	assert.Equal(t, 0, res.Steps[1].Round)
+	assert.Nil(t, res.Pending)
+	require.Len(t, client.calls, 2)
+}
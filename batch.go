@@ -0,0 +1,319 @@
+package llm_client
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"google.golang.org/genai"
+)
+
+// BatchOptions configures how RunBatch executes a set of ChatRequests.
+type BatchOptions struct {
+	Concurrency    int           // Maximum number of requests in flight (defaults to 1)
+	MaxRetries     int           // Extra attempts per request after the first failure
+	RetryBackoff   time.Duration // Delay before the first retry, doubled on every further retry
+	RequestTimeout time.Duration // Optional per-attempt timeout (0 = no timeout)
+	MinInterval    time.Duration // Minimum spacing between request starts, a simple rate limit
+	CheckpointFile string        // Optional JSONL file used to record finished requests and resume later
+	OnProgress     func(BatchProgress)
+	// Retryable reports whether a failed attempt is retried, defaults to IsRetryable
+	Retryable func(error) bool
+}
+
+// BatchProgress is passed to BatchOptions.OnProgress after every finished request.
+type BatchProgress struct {
+	Total     int // Number of requests known so far (final once the input is exhausted)
+	Completed int // Requests that finished successfully (including those restored from a checkpoint)
+	Failed    int // Requests that failed after all retries
+}
+
+// BatchResult holds the outcome of a single request, Index is its position in the input.
+type BatchResult struct {
+	Index    int
+	Response ChatResponse
+	Err      error
+	Attempts int  // Number of attempts made (0 when restored from a checkpoint)
+	Restored bool // True when the result was loaded from the checkpoint file
+}
+
+// batchCheckpoint is a single line of the checkpoint JSONL file.
+type batchCheckpoint struct {
+	Index    int          `json:"index"`
+	Hash     string       `json:"hash"` // identifies the request, see requestHash
+	Response ChatResponse `json:"response"`
+	Error    string       `json:"error,omitempty"`
+}
+
+type batchJob struct {
+	index int
+	hash  string
+	req   ChatRequest
+}
+
+// requestHash identifies a request in the checkpoint so results are only restored for the
+// same request at the same index.
+func requestHash(req ChatRequest) string {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// IsRetryable reports whether a failed request may succeed when sent again: network errors,
+// timeouts of an attempt, rate limits and server errors are retried. Cancellation, requests
+// the model does not support and other 4xx responses are not.
+func IsRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrUnsupportedRequest) ||
+		errors.Is(err, ErrContextWindowExceeded) {
+		return false
+	}
+	status := 0
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	var genaiErr genai.APIError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	case errors.As(err, &genaiErr):
+		status = genaiErr.Code
+	}
+	switch {
+	case status == 408 || status == 409 || status == 429:
+		return true
+	case status >= 400 && status < 500:
+		return false
+	}
+	return true
+}
+
+// RunBatch executes reqs against client with bounded concurrency and returns one result per
+// request in the same order as reqs. Per-request failures are reported in BatchResult.Err,
+// the returned error is only set when the batch itself could not run (checkpoint I/O or ctx).
+func RunBatch(ctx context.Context, client AIClient, reqs []ChatRequest, opts BatchOptions) ([]BatchResult, error) {
+	in := make(chan ChatRequest)
+	go func() {
+		defer close(in)
+		for _, r := range reqs {
+			select {
+			case in <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return runBatch(ctx, client, in, len(reqs), opts)
+}
+
+// RunBatchChan is like RunBatch but reads requests from a channel until it is closed.
+// Results are ordered by the position in which requests were received.
+func RunBatchChan(ctx context.Context, client AIClient, reqs <-chan ChatRequest, opts BatchOptions) ([]BatchResult, error) {
+	return runBatch(ctx, client, reqs, 0, opts)
+}
+
+func runBatch(ctx context.Context, client AIClient, reqs <-chan ChatRequest, total int, opts BatchOptions) ([]BatchResult, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+
+	done, err := loadBatchCheckpoint(opts.CheckpointFile)
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint *os.File
+	if opts.CheckpointFile != "" {
+		checkpoint, err = os.OpenFile(opts.CheckpointFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("open checkpoint file: %w", err)
+		}
+		defer checkpoint.Close()
+	}
+
+	var (
+		mu       sync.Mutex
+		results  []BatchResult
+		progress = BatchProgress{Total: total}
+		writeErr error
+	)
+	// record stores a result and writes it to the checkpoint; mu must not be held.
+	record := func(res BatchResult, hash string, persist bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		for len(results) <= res.Index {
+			results = append(results, BatchResult{Index: len(results)})
+		}
+		results[res.Index] = res
+		if res.Err != nil {
+			progress.Failed++
+		} else {
+			progress.Completed++
+		}
+		if persist && checkpoint != nil && writeErr == nil {
+			line := batchCheckpoint{Index: res.Index, Hash: hash, Response: res.Response}
+			if res.Err != nil {
+				line.Error = res.Err.Error()
+			}
+			data, err := json.Marshal(line)
+			if err == nil {
+				_, err = checkpoint.Write(append(data, '\n'))
+			}
+			if err != nil {
+				writeErr = fmt.Errorf("write checkpoint: %w", err)
+			}
+		}
+		if opts.OnProgress != nil {
+			opts.OnProgress(progress)
+		}
+	}
+
+	jobs := make(chan batchJob)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				resp, attempts, err := runBatchRequest(ctx, client, job.req, opts)
+				record(BatchResult{Index: job.index, Response: resp, Err: err, Attempts: attempts}, job.hash, true)
+			}
+		}()
+	}
+
+	var throttle <-chan time.Time
+	if opts.MinInterval > 0 {
+		ticker := time.NewTicker(opts.MinInterval)
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	index := 0
+feed:
+	for {
+		select {
+		case req, ok := <-reqs:
+			if !ok {
+				break feed
+			}
+			if total == 0 {
+				mu.Lock()
+				progress.Total = index + 1
+				mu.Unlock()
+			}
+			hash := requestHash(req)
+			// entries recorded for a different request at this index are stale
+			if cp, found := done[index]; found && cp.Hash != "" && cp.Hash == hash {
+				record(BatchResult{Index: index, Response: cp.Response, Restored: true}, hash, false)
+				index++
+				continue
+			}
+			if throttle != nil && index > 0 {
+				select {
+				case <-throttle:
+				case <-ctx.Done():
+					break feed
+				}
+			}
+			select {
+			case jobs <- batchJob{index: index, hash: hash, req: req}:
+			case <-ctx.Done():
+				break feed
+			}
+			index++
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if writeErr != nil {
+		return results, writeErr
+	}
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// runBatchRequest runs a single request, retrying retryable failures with exponential backoff.
+func runBatchRequest(ctx context.Context, client AIClient, req ChatRequest, opts BatchOptions) (ChatResponse, int, error) {
+	retryable := opts.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	backoff := opts.RetryBackoff
+	var lastErr error
+	for attempt := 0; attempt <= opts.MaxRetries; attempt++ {
+		if attempt > 0 && backoff > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ChatResponse{}, attempt, ctx.Err()
+			}
+			backoff *= 2
+		}
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if opts.RequestTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, opts.RequestTimeout)
+		}
+		resp, err := client.ChatCompletion(attemptCtx, req)
+		cancel()
+		if err == nil {
+			return resp, attempt + 1, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil || !retryable(err) {
+			return ChatResponse{}, attempt + 1, err
+		}
+	}
+	return ChatResponse{}, opts.MaxRetries + 1, lastErr
+}
+
+// loadBatchCheckpoint reads the successful results already recorded in fileName.
+// Failed entries are ignored so they are retried on resume.
+func loadBatchCheckpoint(fileName string) (map[int]batchCheckpoint, error) {
+	done := map[int]batchCheckpoint{}
+	if fileName == "" {
+		return done, nil
+	}
+	f, err := os.Open(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open checkpoint file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var cp batchCheckpoint
+		if err := json.Unmarshal(scanner.Bytes(), &cp); err != nil {
+			// a partially written last line from an interrupted run is skipped
+			continue
+		}
+		if cp.Error == "" {
+			done[cp.Index] = cp
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read checkpoint file: %w", err)
+	}
+	return done, nil
+}
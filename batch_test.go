@@ -0,0 +1,177 @@
+package llm_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+func echoChat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	// later requests finish first to make sure ordering does not depend on completion time
+	time.Sleep(time.Duration(10-len(req.Messages[0].Content)) * time.Millisecond)
+	return ChatResponse{Choices: []GenChoice{{Content: req.Messages[0].Content}}}, nil
+}
+
+func batchRequests(contents ...string) []ChatRequest {
+	var reqs []ChatRequest
+	for _, c := range contents {
+		reqs = append(reqs, ChatRequest{Messages: []Message{{Role: RoleUser, Content: c}}})
+	}
+	return reqs
+}
+
+func TestRunBatchOrdering(t *testing.T) {
+	var inFlight, maxInFlight int32
+	client := &fakeClient{chat: func(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		return echoChat(ctx, req)
+	}}
+
+	var last BatchProgress
+	results, err := RunBatch(context.Background(), client, batchRequests("a", "bb", "ccc", "dddd", "eeeee"), BatchOptions{
+		Concurrency: 2,
+		OnProgress:  func(p BatchProgress) { last = p },
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	for i, want := range []string{"a", "bb", "ccc", "dddd", "eeeee"} {
+		assert.Equal(t, i, results[i].Index)
+		assert.Equal(t, want, results[i].Response.Choices[0].Content)
+	}
+	assert.LessOrEqual(t, maxInFlight, int32(2))
+	assert.Equal(t, BatchProgress{Total: 5, Completed: 5}, last)
+}
+
+func TestRunBatchRetries(t *testing.T) {
+	var calls int32
+	client := &fakeClient{chat: func(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return ChatResponse{}, errors.New("rate limited")
+		}
+		return echoChat(ctx, req)
+	}}
+
+	results, err := RunBatch(context.Background(), client, batchRequests("a"), BatchOptions{MaxRetries: 2})
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, 3, results[0].Attempts)
+
+	atomic.StoreInt32(&calls, 0)
+	results, err = RunBatch(context.Background(), client, batchRequests("a"), BatchOptions{MaxRetries: 1})
+	require.NoError(t, err)
+	assert.EqualError(t, results[0].Err, "rate limited")
+}
+
+func TestRunBatchCheckpointResume(t *testing.T) {
+	checkpoint := filepath.Join(t.TempDir(), "batch.jsonl")
+	reqs := batchRequests("a", "bb", "ccc")
+
+	failing := &fakeClient{chat: func(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+		if req.Messages[0].Content == "bb" {
+			return ChatResponse{}, errors.New("boom")
+		}
+		return echoChat(ctx, req)
+	}}
+	results, err := RunBatch(context.Background(), failing, reqs, BatchOptions{CheckpointFile: checkpoint})
+	require.NoError(t, err)
+	assert.Error(t, results[1].Err)
+
+	var calls []string
+	client := &fakeClient{chat: func(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+		calls = append(calls, req.Messages[0].Content)
+		return echoChat(ctx, req)
+	}}
+	results, err = RunBatch(context.Background(), client, reqs, BatchOptions{CheckpointFile: checkpoint})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bb"}, calls)
+	assert.True(t, results[0].Restored)
+	assert.True(t, results[2].Restored)
+	assert.Equal(t, "bb", results[1].Response.Choices[0].Content)
+}
+
+func TestRunBatchCheckpointChangedRequests(t *testing.T) {
+	checkpoint := filepath.Join(t.TempDir(), "batch.jsonl")
+	_, err := RunBatch(context.Background(), &fakeClient{chat: echoChat}, batchRequests("a", "bb"), BatchOptions{CheckpointFile: checkpoint})
+	require.NoError(t, err)
+
+	// the request at index 1 changed, its old result must not be restored
+	var calls []string
+	client := &fakeClient{chat: func(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+		calls = append(calls, req.Messages[0].Content)
+		return echoChat(ctx, req)
+	}}
+	results, err := RunBatch(context.Background(), client, batchRequests("a", "cc"), BatchOptions{CheckpointFile: checkpoint})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cc"}, calls)
+	assert.True(t, results[0].Restored)
+	assert.False(t, results[1].Restored)
+	assert.Equal(t, "cc", results[1].Response.Choices[0].Content)
+}
+
+func TestRunBatchPermanentErrors(t *testing.T) {
+	type TestCase struct {
+		name      string
+		err       error
+		retryable bool
+	}
+	for _, tc := range []TestCase{
+		{name: "network", err: errors.New("connection reset"), retryable: true},
+		{name: "canceled", err: context.Canceled},
+		{name: "attempt timeout", err: context.DeadlineExceeded, retryable: true},
+		{name: "unsupported", err: fmt.Errorf("%w: tools", ErrUnsupportedRequest)},
+		{name: "openai bad request", err: &openai.APIError{HTTPStatusCode: 400, Message: "bad"}},
+		{name: "openai rate limit", err: &openai.APIError{HTTPStatusCode: 429, Message: "slow down"}, retryable: true},
+		{name: "openai server", err: &openai.RequestError{HTTPStatusCode: 503}, retryable: true},
+		{name: "gemini not found", err: fmt.Errorf("generate: %w", genai.APIError{Code: 404})},
+		{name: "gemini server", err: genai.APIError{Code: 500}, retryable: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.retryable, IsRetryable(tc.err))
+
+			var calls int32
+			client := &fakeClient{chat: func(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+				atomic.AddInt32(&calls, 1)
+				return ChatResponse{}, tc.err
+			}}
+			results, err := RunBatch(context.Background(), client, batchRequests("a"), BatchOptions{MaxRetries: 2, RetryBackoff: time.Millisecond})
+			require.NoError(t, err)
+			assert.EqualError(t, results[0].Err, tc.err.Error())
+			want := 1
+			if tc.retryable {
+				want = 3
+			}
+			assert.Equal(t, want, results[0].Attempts)
+			assert.Equal(t, int32(want), atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func TestRunBatchChan(t *testing.T) {
+	in := make(chan ChatRequest)
+	go func() {
+		defer close(in)
+		for _, r := range batchRequests("x", "yy", "zzz") {
+			in <- r
+		}
+	}()
+	results, err := RunBatchChan(context.Background(), &fakeClient{chat: echoChat}, in, BatchOptions{Concurrency: 3})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, "zzz", results[2].Response.Choices[0].Content)
+}
@@ -2,9 +2,52 @@ package llm_client
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"sync"
+	"time"
+
 	"github.com/HiroCloud/llm-client/llm_models"
 	t "github.com/HiroCloud/llm-client/tools"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// ToolLoopOptions configures ResolveChatWithOptions.
+type ToolLoopOptions struct {
+	// MaxCalls is a safety limit on the total number of function calls to execute (to avoid infinite loops).
+	// A turn whose calls would exceed it is not run, the loop stops with StopMaxCalls.
+	MaxCalls int
+	// Memory, if set, trims the conversation to the model's context window before every request.
+	Memory *Memory
+	// Parallel is the number of function calls of one model turn executed concurrently.
+	// Values below 2 run the calls one after another. Tools marked Sequential always run alone.
+	Parallel int
+	// MaxConsecutiveFailures aborts the loop with ErrTooManyToolFailures once that many tool calls
+	// failed in a row. Failed calls are reported to the model as a structured error result;
+	// 0 never aborts.
+	MaxConsecutiveFailures int
+	// RecoverInvalidCalls answers calls to unknown tools and calls with arguments that do not
+	// match the tool's parameters with an error result, so the model can try again, instead of
+	// aborting. Such calls are limited by MaxInvalidCalls rather than MaxConsecutiveFailures.
+	RecoverInvalidCalls bool
+	// MaxInvalidCalls is the number of invalid calls tolerated with RecoverInvalidCalls (defaults to 3).
+	MaxInvalidCalls int
+	// Approver decides on calls to tools requiring approval, see llm_models.Tool.Approval.
+	// Without an Approver such calls are rejected.
+	Approver Approver
+	// Checkpoint, if set, receives the RunState of the run identified by RunID after every step,
+	// see ResumeRun.
+	Checkpoint CheckpointStore
+	RunID      string
+}
+
+var (
+	// ErrTooManyToolFailures is returned when tool calls keep failing, see ToolLoopOptions.MaxConsecutiveFailures.
+	ErrTooManyToolFailures = errors.New("too many consecutive tool failures")
+	// ErrTooManyInvalidCalls is returned when the model keeps sending invalid calls, see ToolLoopOptions.RecoverInvalidCalls.
+	ErrTooManyInvalidCalls = errors.New("too many invalid tool calls")
 )
 
 // ResolveChatWithTools drives a chat with an AI model that can call functions (tools).
@@ -20,93 +63,401 @@ func ResolveChatWithTools(
 	tools []llm_models.Tool,
 	maxCalls int,
 ) (string, error) {
+	return ResolveChatWithOptions(ctx, client, messages, tools, ToolLoopOptions{MaxCalls: maxCalls})
+}
+
+// ResolveChatWithOptions is ResolveChatWithTools with additional options, see ToolLoopOptions.
+func ResolveChatWithOptions(
+	ctx context.Context,
+	client AIClient,
+	messages []Message,
+	tools []llm_models.Tool,
+	opts ToolLoopOptions,
+) (string, error) {
+	res, err := RunTools(ctx, client, messages, tools, opts)
+	if err != nil {
+		return "", err
+	}
+	return res.Answer, nil
+}
+
+// RunTools is ResolveChatWithOptions returning a RunResult with the transcript, usage and the
+// executed tool calls. On error the result describes the run up to the failure.
+func RunTools(
+	ctx context.Context,
+	client AIClient,
+	messages []Message,
+	tools []llm_models.Tool,
+	opts ToolLoopOptions,
+) (*RunResult, error) {
+	return runTools(ctx, client, tools, opts, &RunResult{Messages: messages}, nil)
+}
+
+// ResumeTools continues a run that stopped with StopPaused. The Approver is asked again for
+// the calls of the paused turn, none of which has been executed yet.
+func ResumeTools(
+	ctx context.Context,
+	client AIClient,
+	paused *RunResult,
+	tools []llm_models.Tool,
+	opts ToolLoopOptions,
+) (*RunResult, error) {
+	if paused.StopReason != StopPaused {
+		return nil, fmt.Errorf("cannot resume a run stopped with %q", paused.StopReason)
+	}
+	res := *paused
+	res.StopReason = ""
+	res.Pending = nil
+	return runTools(ctx, client, tools, opts, &res, paused.Pending)
+}
+
+// runTools runs the tool loop starting from the state in res, beginning with the calls of an
+// unfinished turn when pending is set.
+func runTools(
+	ctx context.Context,
+	client AIClient,
+	tools []llm_models.Tool,
+	opts ToolLoopOptions,
+	res *RunResult,
+	pending []PendingCall,
+) (*RunResult, error) {
+	if opts.Checkpoint != nil && opts.RunID == "" {
+		return nil, errors.New("checkpoint requires a RunID")
+	}
+	var cp *runCheckpoint
+	if opts.Checkpoint != nil {
+		cp = &runCheckpoint{store: opts.Checkpoint, state: RunState{ID: opts.RunID}}
+	}
+	// messages is the full transcript, prompt what is sent to the model: the transcript as
+	// trimmed by opts.Memory. Both are appended to, so summaries are not redone every round.
+	messages := res.Messages
+	prompt := slices.Clone(messages)
+	appendMessages := func(msgs ...Message) {
+		messages = append(messages, msgs...)
+		prompt = append(prompt, msgs...)
+	}
+	callCount := res.CallCount
+	// checkpoint saves the run with count calls and the pending calls of the current turn
+	checkpoint := func(reason StopReason, count int, pending []PendingCall) error {
+		return cp.save(ctx, func(s *RunState) {
+			s.Messages, s.CallCount, s.Pending = messages, count, pending
+			s.Usage, s.Rounds, s.Steps = res.Usage, res.Rounds, res.Steps
+			s.StopReason, s.Answer = reason, res.Answer
+		})
+	}
+	stop := func(reason StopReason, err error) (*RunResult, error) {
+		res.StopReason = reason
+		res.Messages = messages
+		res.CallCount = callCount
+		if cpErr := checkpoint(reason, callCount, res.Pending); cpErr != nil && err == nil {
+			err = cpErr
+		}
+		return res, err
+	}
+
+	maxCalls := opts.MaxCalls
+	maxInvalid := opts.MaxInvalidCalls
+	if maxInvalid <= 0 {
+		maxInvalid = 3
+	}
+
 	// Prepare a lookup map for tools by name for convenience.
 	toolMap := make(map[string]llm_models.Tool)
+	toolNames := make([]string, 0, len(tools))
 	for _, tool := range tools {
 		name := tool.Function.Name // assuming FuncDef has a Name field
 		toolMap[name] = tool
+		toolNames = append(toolNames, name)
 	}
 
-	callCount := 0
+	failures := 0     // consecutive failed tool calls
+	invalidCalls := 0 // calls answered with an error by RecoverInvalidCalls
 	for callCount < maxCalls {
-		// Ask the AI model for the next response (which may include function call requests).
-		result, err := client.GenerateResponse(ctx, messages, tools)
-		if err != nil {
-			return "", fmt.Errorf("AIClient generation error: %w", err)
-		}
+		var turn []PendingCall
+		round := len(res.Rounds) - 1
+		if pending != nil {
+			// Resume the unfinished turn without asking the model again.
+			turn, pending = pending, nil
+		} else {
+			// Keep the conversation inside the model's context window.
+			if opts.Memory != nil {
+				fitted, err := opts.Memory.Fit(ctx, prompt)
+				if err != nil {
+					return stop(StopError, err)
+				}
+				prompt = slices.Clone(fitted)
+			}
 
-		// Check if the model is requesting any function calls.
-		if len(result.FunctionCalls) == 0 {
-			// No function call means the model has produced a final answer.
-			finalAnswer := result.Content // assume Content holds the assistant's reply
-			return finalAnswer, nil
+			// Ask the AI model for the next response (which may include function call requests).
+			result, err := client.GenerateResponse(ctx, prompt, tools)
+			if err != nil {
+				return stop(StopError, fmt.Errorf("AIClient generation error: %w", err))
+			}
+			round++
+			res.Rounds = append(res.Rounds, result.Usage)
+			res.Usage.add(result.Usage)
+
+			// Check if the model is requesting any function calls.
+			if len(result.FunctionCalls) == 0 {
+				// No function call means the model has produced a final answer.
+				res.Answer = result.Content // assume Content holds the assistant's reply
+				appendMessages(Message{Role: RoleAssistant, Content: result.Content})
+				return stop(StopFinalAnswer, nil)
+			}
+			for _, fc := range result.FunctionCalls {
+				turn = append(turn, PendingCall{Call: fc})
+			}
 		}
 
 		// The model has requested one or more function calls (possibly parallel calls).
-		for _, fc := range result.FunctionCalls {
-			callCount++
-			// Prevent exceeding maxCalls in the middle of processing multiple calls
-			if callCount > maxCalls {
-				return "", fmt.Errorf("maxCalls limit (%d) exceeded – aborting to prevent infinite loop", maxCalls)
-			}
+		// Every call of a turn must be answered, so a turn crossing maxCalls runs none of its calls.
+		if callCount+len(turn) > maxCalls {
+			return stop(StopMaxCalls, fmt.Errorf("maxCalls limit (%d) reached: %d function calls made, the model requested %d more",
+				maxCalls, callCount, len(turn)))
+		}
+		turnStart := callCount
+		callCount += len(turn)
+		calls := make([]*toolCall, 0, len(turn))
+		for _, p := range turn {
+			fc := p.Call
 
 			toolName := fc.Name
 			tool, ok := toolMap[toolName]
+			if p.Done || p.Started {
+				// Restored from a checkpoint, never run a call twice.
+				calls = append(calls, restoredCall(p, tool))
+				continue
+			}
+			if !ok && opts.RecoverInvalidCalls {
+				err := fmt.Errorf("unknown tool '%s'", toolName)
+				data, _ := json.Marshal(toolError{Error: err.Error(), Type: "unknown_tool", Tool: toolName, ValidTools: toolNames})
+				calls = append(calls, &toolCall{call: fc, result: string(data), err: err, invalid: true, done: true})
+				continue
+			}
 			if !ok {
 				// Unknown function requested by the model.
 				errMsg := fmt.Sprintf("model requested unknown tool '%s'", toolName)
 				// Append an error message for transparency, then stop.
-				messages = append(messages, Message{
+				appendMessages(Message{
 					Role:    "assistant",
 					Content: errMsg,
 				})
-				return "", fmt.Errorf(errMsg)
+				return stop(StopError, errors.New(errMsg))
 			}
-
-			// Use reflection or provided CallTool utility to invoke the tool function with arguments.
-			var toolResult interface{}
-			var toolErr error
-			if tool.CallFunc != nil {
-				// CallTool is assumed to execute the function pointer with the given arguments map.
-				toolResult, toolErr = t.CallJSONStr(ctx, &tool, fc.Arguments)
-			} else {
-				return "", fmt.Errorf("call func does not exist")
+			if tool.CallFunc == nil {
+				return stop(StopError, fmt.Errorf("call func does not exist"))
 			}
+			calls = append(calls, &toolCall{call: fc, tool: tool})
+		}
+
+		// Ask for approval before anything of this turn runs.
+		paused, err := approveCalls(ctx, opts.Approver, calls)
+		if err != nil {
+			return stop(StopError, err)
+		}
+		if paused {
+			res.Pending = turn
+			callCount = turnStart
+			return stop(StopPaused, nil)
+		}
 
-			// Format the tool result for the chat. Use WriteToChat if available, otherwise default formatting.
-			var resultText string
-			if toolErr != nil {
-				// If the tool returned an error, include that info.
-				resultText = fmt.Sprintf("Error calling %s: %v", toolName, toolErr)
-			} else {
-				resultText = fmt.Sprintf("%v", toolResult)
+		if cp != nil {
+			turn = make([]PendingCall, 0, len(calls))
+			for i, c := range calls {
+				c.cp, c.index = cp, i
+				turn = append(turn, c.pending())
+			}
+			if err := checkpoint("", turnStart, turn); err != nil {
+				return stop(StopError, err)
 			}
+		}
+		executed := runToolCalls(ctx, calls, opts.Parallel, opts.RecoverInvalidCalls)
+		if cp != nil && cp.err != nil {
+			return stop(StopError, fmt.Errorf("checkpoint: %w", cp.err))
+		}
 
+		for _, c := range calls[:executed] {
 			// Append the function call and its result to the conversation history.
 			// a) Record the assistant's function call (for the model's context).
-			messages = append(messages, Message{
+			appendMessages(Message{
 				Role:         "assistant",
-				Content:      "", // no direct content, but we set the function call info
-				FunctionCall: fc, // store the function call details (name & arguments)
-			})
-			// b) Record the function's response as a message from the function.
-			messages = append(messages, Message{
-				Role:    "function",
-				Name:    toolName,
-				Content: resultText, // output from the tool (formatted)
+				Content:      "",     // no direct content, but we set the function call info
+				FunctionCall: c.call, // store the function call details (name & arguments)
+			}, Message{
+				// b) Record the function's response as a message from the function.
+				Role:         "function",
+				Name:         c.call.Name,
+				Content:      c.result, // output from the tool (formatted)
+				MultiContent: c.parts,  // images or other content returned by the tool
 			})
+			res.Steps = append(res.Steps, c.step(round))
+
+			switch {
+			case c.rejected:
+				// the model was told why, it may ask again
+				continue
+			case c.invalid && opts.RecoverInvalidCalls:
+				invalidCalls++
+				if invalidCalls > maxInvalid {
+					return stop(StopError, fmt.Errorf("%w: %d calls, last: %w", ErrTooManyInvalidCalls, invalidCalls, c.err))
+				}
+				// let the model retry instead of exiting with an error
+				continue
+			case c.err != nil:
+				failures++
+			default:
+				failures = 0
+			}
+			if opts.MaxConsecutiveFailures > 0 && failures >= opts.MaxConsecutiveFailures {
+				return stop(StopError, fmt.Errorf("%w: %d in a row, last: %w", ErrTooManyToolFailures, failures, c.err))
+			}
 
 			// If this tool is an exit signal, we break out early with its result.
-			if tool.ExitFunc {
-				return resultText, nil
+			if c.tool.ExitFunc {
+				res.Answer = c.result
+				res.ExitValues = c.values
+				return stop(StopExitTool, nil)
 			}
 		}
 
+		if err := checkpoint("", callCount, nil); err != nil {
+			return stop(StopError, err)
+		}
+
 		// After executing all requested function calls, loop continues.
 		// The updated `messages` (with tool call results) will be sent in the next iteration
 		// to get the model's follow-up response.
 	}
 
 	// If we exit the loop due to maxCalls exhaustion, return an error.
-	return "", fmt.Errorf("stopped after %d function calls to prevent infinite loop", maxCalls)
+	return stop(StopMaxCalls, fmt.Errorf("stopped after %d function calls to prevent infinite loop", maxCalls))
+}
+
+// toolCall is a function call requested by the model together with the tool serving it.
+type toolCall struct {
+	call   *FunctionCall
+	tool   llm_models.Tool
+	result string                       // formatted result for the transcript
+	err    error                        // set when the call failed, result then holds a toolError
+	parts  []llm_models.ChatMessagePart // multimodal content returned by the tool
+	values []interface{}                // values returned by the tool
+	took   time.Duration
+	// invalid marks calls to unknown tools or with arguments not matching the parameters
+	invalid  bool
+	rejected bool // the Approver rejected the call
+	done     bool // result is set without running the tool
+	started  bool // the tool was invoked
+	finished bool // the tool returned
+	// cp records the progress of the call as Pending[index] of the run's checkpoint
+	cp    *runCheckpoint
+	index int
+}
+
+// runToolCalls executes calls with up to parallel calls at a time. Sequential and exit tools
+// run alone once the calls before them have finished, and no call after an exit tool ending
+// the run is executed. It returns the number of executed calls.
+func runToolCalls(ctx context.Context, calls []*toolCall, parallel int, recoverInvalid bool) int {
+	start := 0 // first call of the pending concurrent group
+	for i, c := range calls {
+		if parallel > 1 && !c.tool.Sequential && !c.tool.ExitFunc {
+			continue
+		}
+		runConcurrently(ctx, calls[start:i], parallel)
+		executeToolCall(ctx, c)
+		start = i + 1
+		if c.exits(recoverInvalid) {
+			return i + 1
+		}
+	}
+	runConcurrently(ctx, calls[start:], parallel)
+	return len(calls)
+}
+
+// exits reports whether the executed call ends the run: exit tools do unless the call was
+// rejected or invalid with recoverInvalid.
+func (c *toolCall) exits(recoverInvalid bool) bool {
+	return c.tool.ExitFunc && !c.rejected && !(c.invalid && recoverInvalid)
+}
+
+// runConcurrently executes calls on at most parallel goroutines.
+func runConcurrently(ctx context.Context, calls []*toolCall, parallel int) {
+	if len(calls) == 0 {
+		return
+	}
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for _, c := range calls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(c *toolCall) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			executeToolCall(ctx, c)
+		}(c)
+	}
+	wg.Wait()
+}
+
+// executeToolCall invokes the tool and formats its result for the chat.
+func executeToolCall(ctx context.Context, c *toolCall) {
+	if c.done {
+		// already answered
+		return
+	}
+	if err := c.cp.started(ctx, c.index); err != nil {
+		// without a record of the call it must not run
+		c.err = fmt.Errorf("checkpoint: %w", err)
+		c.result = toolErrorResult(&c.tool, c.err)
+		return
+	}
+	c.started = true
+	defer func() {
+		c.finished = true
+		c.cp.finished(ctx, c)
+	}()
+	// Use reflection to invoke the tool function with the JSON arguments.
+	start := time.Now()
+	toolResult, toolErr := t.CallJSONStr(ctx, &c.tool, c.call.Arguments)
+	c.took = time.Since(start)
+	c.values = toolResult
+	var out t.ToolResult
+	if toolErr == nil {
+		out, toolErr = t.FormatResult(&c.tool, toolResult)
+		if toolErr != nil {
+			toolErr = fmt.Errorf("format result: %w", toolErr)
+		}
+	}
+	if toolErr != nil {
+		// If the tool failed, tell the model what went wrong so it can react.
+		c.err = toolErr
+		c.invalid = errors.Is(toolErr, t.ErrInvalidArguments)
+		c.result = toolErrorResult(&c.tool, toolErr)
+		return
+	}
+	c.result, c.parts = out.Text, out.Parts
+}
+
+// toolError is the function result sent to the model when a tool call fails.
+type toolError struct {
+	Error      string                 `json:"error"`
+	Type       string                 `json:"type"` // "timeout", "panic", "invalid_arguments", "unknown_tool", "rejected", "interrupted" or "error"
+	Tool       string                 `json:"tool"`
+	Parameters *jsonschema.Definition `json:"parameters,omitempty"`  // expected parameters for invalid_arguments
+	ValidTools []string               `json:"valid_tools,omitempty"` // available tools for unknown_tool
+}
+
+// toolErrorResult formats err returned by tool as a JSON toolError.
+func toolErrorResult(tool *llm_models.Tool, err error) string {
+	e := toolError{Error: err.Error(), Type: "error", Tool: tool.Function.Name}
+	switch {
+	case errors.Is(err, t.ErrToolTimeout):
+		e.Type = "timeout"
+	case errors.Is(err, t.ErrToolPanic):
+		e.Type = "panic"
+	case errors.Is(err, t.ErrInvalidArguments):
+		e.Type = "invalid_arguments"
+		e.Parameters = &tool.Function.Parameters
+	}
+	data, _ := json.Marshal(e)
+	return string(data)
 }
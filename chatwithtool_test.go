@@ -0,0 +1,397 @@
+package llm_client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/HiroCloud/llm-client/tools"
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+// concurrencyProbe records how many tool calls run at the same time.
+type concurrencyProbe struct {
+	active, max atomic.Int32
+}
+
+func (p *concurrencyProbe) enter() int32 {
+	n := p.active.Add(1)
+	for {
+		m := p.max.Load()
+		if n <= m || p.max.CompareAndSwap(m, n) {
+			return n
+		}
+	}
+}
+
+func (p *concurrencyProbe) leave() {
+	p.active.Add(-1)
+}
+
+func idTool(name string, fn func(ctx context.Context, id string) string) llm_models.Tool {
+	return llm_models.Tool{
+		Function: llm_models.FuncDef{
+			Name:       name,
+			ParamOrder: []string{"id"},
+			Parameters: jsonschema.Definition{Required: []string{"id"}},
+		},
+		CallFunc: fn,
+	}
+}
+
+func callsTurn(name string, ids ...string) Response {
+	var calls []*FunctionCall
+	for _, id := range ids {
+		calls = append(calls, &FunctionCall{ID: id, Name: name, Arguments: `{"id":"` + id + `"}`})
+	}
+	return Response{FunctionCalls: calls}
+}
+
+func TestResolveChatParallel(t *testing.T) {
+	probe := &concurrencyProbe{}
+	var release sync.WaitGroup
+	release.Add(3)
+	fetch := idTool("fetch", func(ctx context.Context, id string) string {
+		probe.enter()
+		defer probe.leave()
+		release.Done()
+		// wait until all calls started, or give up so a sequential run still finishes
+		done := make(chan struct{})
+		go func() { release.Wait(); close(done) }()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+		// finish in reverse order
+		if id == "a" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return "result " + id
+	})
+
+	client := &fakeClient{responses: []Response{callsTurn("fetch", "a", "b", "c"), {Content: "done"}}}
+	answer, err := ResolveChatWithOptions(context.Background(), client, []Message{{Role: RoleUser, Content: "go"}},
+		[]llm_models.Tool{fetch}, ToolLoopOptions{MaxCalls: 5, Parallel: 3})
+	require.NoError(t, err)
+	assert.Equal(t, "done", answer)
+	assert.Equal(t, int32(3), probe.max.Load())
+
+	// results keep the order of the calls
+	last := client.calls[1]
+	require.Len(t, last, 7)
+	for i, id := range []string{"a", "b", "c"} {
+		assert.Equal(t, id, last[1+2*i].FunctionCall.ID)
+		assert.Equal(t, "result "+id, last[2+2*i].Content)
+	}
+}
+
+func TestResolveChatParallelLimit(t *testing.T) {
+	probe := &concurrencyProbe{}
+	fetch := idTool("fetch", func(ctx context.Context, id string) string {
+		probe.enter()
+		defer probe.leave()
+		time.Sleep(10 * time.Millisecond)
+		return id
+	})
+	var sequentialOverlap atomic.Bool
+	save := idTool("save", func(ctx context.Context, id string) string {
+		if probe.enter() > 1 {
+			sequentialOverlap.Store(true)
+		}
+		defer probe.leave()
+		time.Sleep(10 * time.Millisecond)
+		return "saved " + id
+	})
+	save.Sequential = true
+
+	turn := callsTurn("fetch", "1", "2", "3", "4")
+	turn.FunctionCalls = append(turn.FunctionCalls[:2], append(callsTurn("save", "s").FunctionCalls, turn.FunctionCalls[2:]...)...)
+	client := &fakeClient{responses: []Response{turn, {Content: "done"}}}
+	_, err := ResolveChatWithOptions(context.Background(), client, nil,
+		[]llm_models.Tool{fetch, save}, ToolLoopOptions{MaxCalls: 10, Parallel: 2})
+	require.NoError(t, err)
+	assert.LessOrEqual(t, probe.max.Load(), int32(2))
+	assert.False(t, sequentialOverlap.Load())
+
+	last := client.calls[1]
+	require.Len(t, last, 10)
+	assert.Equal(t, []string{"1", "2", "saved s", "3", "4"},
+		[]string{last[1].Content, last[3].Content, last[5].Content, last[7].Content, last[9].Content})
+}
+
+func TestResolveChatParallelExit(t *testing.T) {
+	var ran atomic.Int32
+	work := idTool("work", func(ctx context.Context, id string) string {
+		ran.Add(1)
+		return id
+	})
+	finish := idTool("finish", func(ctx context.Context, id string) string {
+		return "final " + id
+	})
+	finish.ExitFunc = true
+
+	turn := callsTurn("work", "1")
+	turn.FunctionCalls = append(turn.FunctionCalls, callsTurn("finish", "x").FunctionCalls[0], callsTurn("work", "2").FunctionCalls[0])
+	client := &fakeClient{responses: []Response{turn}}
+	answer, err := ResolveChatWithOptions(context.Background(), client, nil,
+		[]llm_models.Tool{work, finish}, ToolLoopOptions{MaxCalls: 10, Parallel: 4})
+	require.NoError(t, err)
+	assert.Equal(t, "final x", answer)
+	// calls after the exit tool are not executed
+	assert.Equal(t, int32(1), ran.Load())
+}
+
+func TestResolveChatToolFailures(t *testing.T) {
+	flaky := idTool("flaky", func(ctx context.Context, id string) string {
+		if id == "panic" {
+			panic("index out of range")
+		}
+		time.Sleep(time.Second)
+		return id
+	})
+	flaky.Timeout = 10 * time.Millisecond
+
+	client := &fakeClient{responses: []Response{
+		callsTurn("flaky", "panic"),
+		callsTurn("flaky", "slow"),
+		{Content: "gave up"},
+	}}
+	answer, err := ResolveChatWithOptions(context.Background(), client, nil,
+		[]llm_models.Tool{flaky}, ToolLoopOptions{MaxCalls: 5})
+	require.NoError(t, err)
+	assert.Equal(t, "gave up", answer)
+
+	// failures are fed back as structured results
+	var first, second toolError
+	require.NoError(t, json.Unmarshal([]byte(client.calls[1][1].Content), &first))
+	assert.Equal(t, "panic", first.Type)
+	assert.Equal(t, "flaky", first.Tool)
+	assert.Contains(t, first.Error, "index out of range")
+	require.NoError(t, json.Unmarshal([]byte(client.calls[2][3].Content), &second))
+	assert.Equal(t, "timeout", second.Type)
+
+	client = &fakeClient{responses: []Response{
+		callsTurn("flaky", "panic"),
+		callsTurn("flaky", "panic"),
+		{Content: "never reached"},
+	}}
+	_, err = ResolveChatWithOptions(context.Background(), client, nil,
+		[]llm_models.Tool{flaky}, ToolLoopOptions{MaxCalls: 5, MaxConsecutiveFailures: 2})
+	assert.ErrorIs(t, err, ErrTooManyToolFailures)
+	assert.ErrorIs(t, err, tools.ErrToolPanic)
+	assert.Len(t, client.calls, 2)
+}
+
+func TestResolveChatRecoverInvalidCalls(t *testing.T) {
+	echo := idTool("echo", func(ctx context.Context, id string) string { return id })
+	echo.Function.Parameters.Type = "object"
+
+	client := &fakeClient{responses: []Response{
+		callsTurn("ecko", "1"),
+		{FunctionCalls: []*FunctionCall{{ID: "2", Name: "echo", Arguments: `{"id": 5`}}},
+		{FunctionCalls: []*FunctionCall{{ID: "3", Name: "echo", Arguments: `{"name":"x"}`}}},
+		callsTurn("echo", "ok"),
+		{Content: "done"},
+	}}
+	answer, err := ResolveChatWithOptions(context.Background(), client, nil,
+		[]llm_models.Tool{echo}, ToolLoopOptions{MaxCalls: 10, RecoverInvalidCalls: true, MaxConsecutiveFailures: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "done", answer)
+
+	var unknown, malformed, missing toolError
+	require.NoError(t, json.Unmarshal([]byte(client.calls[1][1].Content), &unknown))
+	assert.Equal(t, "unknown_tool", unknown.Type)
+	assert.Equal(t, []string{"echo"}, unknown.ValidTools)
+	require.NoError(t, json.Unmarshal([]byte(client.calls[2][3].Content), &malformed))
+	assert.Equal(t, "invalid_arguments", malformed.Type)
+	assert.Contains(t, malformed.Error, "json unmarshal fail")
+	require.NotNil(t, malformed.Parameters)
+	require.NoError(t, json.Unmarshal([]byte(client.calls[3][5].Content), &missing))
+	assert.Contains(t, missing.Error, "missing required parameter: id")
+	assert.Equal(t, "ok", client.calls[4][7].Content)
+
+	// the retry limit is separate from MaxCalls
+	client = &fakeClient{responses: []Response{
+		callsTurn("ecko", "1"), callsTurn("ecko", "2"), callsTurn("ecko", "3"),
+	}}
+	_, err = ResolveChatWithOptions(context.Background(), client, nil,
+		[]llm_models.Tool{echo}, ToolLoopOptions{MaxCalls: 10, RecoverInvalidCalls: true, MaxInvalidCalls: 2})
+	assert.ErrorIs(t, err, ErrTooManyInvalidCalls)
+
+	// without recovery an unknown tool still aborts
+	client = &fakeClient{responses: []Response{callsTurn("ecko", "1")}}
+	_, err = ResolveChatWithOptions(context.Background(), client, nil,
+		[]llm_models.Tool{echo}, ToolLoopOptions{MaxCalls: 10})
+	assert.ErrorContains(t, err, "unknown tool 'ecko'")
+}
+
+type order struct {
+	ID    string
+	Total float64
+}
+
+func TestRunTools(t *testing.T) {
+	lookup := idTool("lookup", func(ctx context.Context, id string) string { return "found " + id })
+	submit := llm_models.Tool{
+		Function: llm_models.FuncDef{Name: "submit", ParamOrder: []string{"id"}},
+		CallFunc: func(id string) (order, error) { return order{ID: id, Total: 9.5}, nil },
+		ExitFunc: true,
+	}
+	client := &fakeClient{responses: []Response{
+		{FunctionCalls: callsTurn("lookup", "a").FunctionCalls, Usage: TokenUsage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}},
+		{FunctionCalls: callsTurn("submit", "o-1").FunctionCalls, Usage: TokenUsage{PromptTokens: 20, CompletionTokens: 3, TotalTokens: 23}},
+	}}
+	res, err := RunTools(context.Background(), client, []Message{{Role: RoleUser, Content: "order"}},
+		[]llm_models.Tool{lookup, submit}, ToolLoopOptions{MaxCalls: 5})
+	require.NoError(t, err)
+	assert.Equal(t, StopExitTool, res.StopReason)
+	assert.Equal(t, TokenUsage{PromptTokens: 30, CompletionTokens: 5, TotalTokens: 35}, res.Usage)
+	assert.Len(t, res.Rounds, 2)
+	assert.Len(t, res.Messages, 5)
+
+	require.Len(t, res.Steps, 2)
+	assert.Equal(t, ToolStep{Round: 0, ID: "a", Tool: "lookup", Arguments: `{"id":"a"}`, Output: "found a",
+		Values: []interface{}{"found a"}, Duration: res.Steps[0].Duration}, res.Steps[0])
+	assert.Equal(t, 1, res.Steps[1].Round)
+
+	o, ok := ExitValue[order](res)
+	require.True(t, ok)
+	assert.Equal(t, order{ID: "o-1", Total: 9.5}, o)
+	_, ok = ExitValue[int](res)
+	assert.False(t, ok)
+
+	client = &fakeClient{responses: []Response{{Content: "hi"}}}
+	res, err = RunTools(context.Background(), client, nil, nil, ToolLoopOptions{MaxCalls: 1})
+	require.NoError(t, err)
+	assert.Equal(t, StopFinalAnswer, res.StopReason)
+	assert.Equal(t, "hi", res.Answer)
+	assert.Equal(t, []Message{{Role: RoleAssistant, Content: "hi"}}, res.Messages)
+
+	client = &fakeClient{responses: []Response{callsTurn("lookup", "a", "b")}}
+	res, err = RunTools(context.Background(), client, nil, []llm_models.Tool{lookup}, ToolLoopOptions{MaxCalls: 1})
+	assert.Error(t, err)
+	assert.Equal(t, StopMaxCalls, res.StopReason)
+}
+
+func TestRunToolsMaxCallsTurn(t *testing.T) {
+	var ran []string
+	lookup := idTool("lookup", func(ctx context.Context, id string) string {
+		ran = append(ran, id)
+		return "found " + id
+	})
+	client := &fakeClient{responses: []Response{callsTurn("lookup", "a", "b"), callsTurn("lookup", "c", "d")}}
+	res, err := RunTools(context.Background(), client, nil, []llm_models.Tool{lookup}, ToolLoopOptions{MaxCalls: 3})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 function calls made, the model requested 2 more")
+	assert.Equal(t, StopMaxCalls, res.StopReason)
+	// the first turn fits the budget and runs, the second would cross it and runs nothing
+	assert.Equal(t, []string{"a", "b"}, ran)
+	assert.Equal(t, 2, res.CallCount)
+	assert.Len(t, res.Steps, 2)
+}
+
+func TestResolveChatToolImages(t *testing.T) {
+	chart := llm_models.Tool{
+		Function: llm_models.FuncDef{Name: "chart", ParamOrder: []string{"id"}},
+		CallFunc: func(id string) (order, llm_models.ChatMessagePart, error) {
+			return order{ID: id}, tools.ImagePart("image/png", []byte("png")), nil
+		},
+	}
+	client := &fakeClient{responses: []Response{callsTurn("chart", "a"), {Content: "a bar chart"}}}
+	_, err := RunTools(context.Background(), client, nil, []llm_models.Tool{chart}, ToolLoopOptions{MaxCalls: 2})
+	require.NoError(t, err)
+
+	result := client.calls[1][1]
+	assert.Equal(t, `{"ID":"a","Total":0}`, result.Content)
+	require.Len(t, result.MultiContent, 1)
+
+	// OpenAI gets the result as text and the image in a user message after it
+	openReq, err := NewOpenAIClient("test", "gpt-4o").openAIChatRequest(ChatRequest{Messages: client.calls[1]})
+	require.NoError(t, err)
+	require.Len(t, openReq.Messages, 3)
+	toolMsg := openReq.Messages[1]
+	assert.Equal(t, RoleFunction, toolMsg.Role)
+	assert.Equal(t, result.Content, toolMsg.Content)
+	assert.Empty(t, toolMsg.MultiContent)
+	imageMsg := openReq.Messages[2]
+	assert.Equal(t, RoleUser, imageMsg.Role)
+	require.Len(t, imageMsg.MultiContent, 2)
+	assert.Equal(t, "Content returned by chart:", imageMsg.MultiContent[0].Text)
+	assert.Equal(t, "data:image/png;base64,cG5n", imageMsg.MultiContent[1].ImageURL.URL)
+
+	inline := googlePart(result.MultiContent[0])
+	require.NotNil(t, inline.InlineData)
+	assert.Equal(t, "image/png", inline.InlineData.MIMEType)
+	assert.Equal(t, []byte("png"), inline.InlineData.Data)
+	remote := googlePart(tools.ImageURLPart("gs://bucket/chart.png"))
+	require.NotNil(t, remote.FileData)
+	assert.Equal(t, "image/png", remote.FileData.MIMEType)
+}
+
+func TestGoogleGenerateResponseTools(t *testing.T) {
+	address := jsonschema.Definition{
+		Type:       jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{"zip": {Type: jsonschema.String}},
+	}
+	ship := llm_models.Tool{Function: llm_models.FuncDef{
+		Name:        "ship",
+		Description: "ships a parcel",
+		Parameters: jsonschema.Definition{
+			Type:       jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{"to": {Ref: "#/$defs/Address"}},
+			Defs:       map[string]jsonschema.Definition{"Address": address},
+		},
+	}}
+
+	var declarations []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Tools []struct {
+				FunctionDeclarations []map[string]any `json:"functionDeclarations"`
+			} `json:"tools"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Len(t, body.Tools, 1)
+		declarations = body.Tools[0].FunctionDeclarations
+		_ = json.NewEncoder(w).Encode(map[string]any{"candidates": []map[string]any{{
+			"content": map[string]any{"parts": []map[string]any{{
+				"functionCall": map[string]any{"name": "ship", "args": map[string]any{"to": map[string]any{"zip": "10115"}}},
+			}}},
+		}}})
+	}))
+	defer server.Close()
+
+	client, err := NewGoogleClient(context.Background(), &genai.ClientConfig{
+		APIKey:      "test",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	}, "gemini-test")
+	require.NoError(t, err)
+	resp, err := client.GenerateResponse(context.Background(), []Message{{Role: RoleUser, Content: "ship it"}}, []llm_models.Tool{ship})
+	require.NoError(t, err)
+	require.Len(t, resp.FunctionCalls, 1)
+	assert.Equal(t, `{"to":{"zip":"10115"}}`, resp.FunctionCalls[0].Arguments)
+
+	// the declaration is sent with its references inlined
+	require.Len(t, declarations, 1)
+	assert.Equal(t, "ship", declarations[0]["name"])
+	assert.Equal(t, "ships a parcel", declarations[0]["description"])
+	params := declarations[0]["parametersJsonSchema"].(map[string]any)
+	assert.NotContains(t, params, "$defs")
+	assert.Equal(t, "string", llm_models.SchemaNode(params, "to.zip")["type"])
+
+	config := googleGenConfig(ChatRequest{Functions: []FunctionDef{{Name: "ship", Parameters: map[string]any{"type": "object"}}}})
+	require.Len(t, config.Tools, 1)
+	require.Len(t, config.Tools[0].FunctionDeclarations, 1)
+	assert.Equal(t, map[string]any{"type": "object"}, config.Tools[0].FunctionDeclarations[0].ParametersJsonSchema)
+	assert.Empty(t, googleGenConfig(ChatRequest{}).Tools)
+}
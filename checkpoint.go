@@ -0,0 +1,266 @@
+package llm_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+)
+
+var (
+	// ErrRunNotFound is returned by a CheckpointStore for an unknown run ID.
+	ErrRunNotFound = errors.New("run not found")
+	// ErrToolInterrupted is reported to the model for calls that were running when the run was
+	// interrupted. They are not repeated since their side effects may already have happened.
+	ErrToolInterrupted = errors.New("tool call interrupted, its outcome is unknown")
+)
+
+// RunState is the serializable state of a tool loop run, saved after every step when
+// ToolLoopOptions.Checkpoint is set.
+type RunState struct {
+	ID         string        `json:"id"`
+	Messages   []Message     `json:"messages"`
+	CallCount  int           `json:"call_count"`
+	Pending    []PendingCall `json:"pending,omitempty"` // calls of the turn in progress
+	Usage      TokenUsage    `json:"usage"`
+	Rounds     []TokenUsage  `json:"rounds,omitempty"`
+	Steps      []ToolStep    `json:"steps,omitempty"`
+	StopReason StopReason    `json:"stop_reason,omitempty"` // empty while the run is in progress
+	Answer     string        `json:"answer,omitempty"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+// PendingCall is a function call of the turn in progress.
+type PendingCall struct {
+	Call     *FunctionCall                `json:"call"`
+	Started  bool                         `json:"started,omitempty"` // the tool was invoked and may have had side effects
+	Done     bool                         `json:"done,omitempty"`    // the result below is final
+	Result   string                       `json:"result,omitempty"`
+	Parts    []llm_models.ChatMessagePart `json:"parts,omitempty"`
+	Error    string                       `json:"error,omitempty"`
+	Invalid  bool                         `json:"invalid,omitempty"`
+	Rejected bool                         `json:"rejected,omitempty"`
+}
+
+// CheckpointStore persists run states.
+type CheckpointStore interface {
+	Save(ctx context.Context, state *RunState) error
+	// Load returns ErrRunNotFound for unknown ids.
+	Load(ctx context.Context, id string) (*RunState, error)
+}
+
+// ResumeRun continues the run saved under id, e.g. after a restart, with the same tools as
+// before. Finished runs are returned as they are. Calls that completed before the interruption
+// keep their result and calls that were running are answered with ErrToolInterrupted, so no
+// tool call is executed twice. The run keeps saving to store.
+func ResumeRun(
+	ctx context.Context,
+	client AIClient,
+	store CheckpointStore,
+	id string,
+	tools []llm_models.Tool,
+	opts ToolLoopOptions,
+) (*RunResult, error) {
+	state, err := store.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	res := &RunResult{
+		Answer:     state.Answer,
+		StopReason: state.StopReason,
+		Messages:   state.Messages,
+		Usage:      state.Usage,
+		Rounds:     state.Rounds,
+		Steps:      state.Steps,
+		CallCount:  state.CallCount,
+	}
+	opts.Checkpoint, opts.RunID = store, id
+	switch state.StopReason {
+	case StopFinalAnswer, StopExitTool:
+		return res, nil
+	case StopPaused:
+		res.Pending = state.Pending
+		return ResumeTools(ctx, client, res, tools, opts)
+	}
+	res.StopReason = ""
+	return runTools(ctx, client, tools, opts, res, state.Pending)
+}
+
+// MemoryCheckpointStore keeps run states in memory.
+type MemoryCheckpointStore struct {
+	mu     sync.Mutex
+	states map[string][]byte
+}
+
+// NewMemoryCheckpointStore creates an empty MemoryCheckpointStore.
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{states: map[string][]byte{}}
+}
+
+func (s *MemoryCheckpointStore) Save(ctx context.Context, state *RunState) error {
+	// store a copy, the run keeps changing its state
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[state.ID] = data
+	return nil
+}
+
+func (s *MemoryCheckpointStore) Load(ctx context.Context, id string) (*RunState, error) {
+	s.mu.Lock()
+	data, ok := s.states[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRunNotFound, id)
+	}
+	var state RunState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// FileCheckpointStore keeps every run state as <Dir>/<id>.json, replacing the file atomically.
+type FileCheckpointStore struct {
+	Dir string
+}
+
+func (s FileCheckpointStore) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid run id %q", id)
+	}
+	return filepath.Join(s.Dir, id+".json"), nil
+}
+
+func (s FileCheckpointStore) Save(ctx context.Context, state *RunState) error {
+	path, err := s.path(state.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.Dir, state.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s FileCheckpointStore) Load(ctx context.Context, id string) (*RunState, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrRunNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state RunState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// runCheckpoint saves the state of a running loop. Tool calls running concurrently update
+// their pending entry through it. A nil runCheckpoint does nothing.
+type runCheckpoint struct {
+	mu    sync.Mutex
+	store CheckpointStore
+	state RunState
+	err   error // first failed save
+}
+
+func (cp *runCheckpoint) save(ctx context.Context, update func(s *RunState)) error {
+	if cp == nil {
+		return nil
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	update(&cp.state)
+	cp.state.UpdatedAt = time.Now()
+	if err := cp.store.Save(ctx, &cp.state); err != nil {
+		if cp.err == nil {
+			cp.err = err
+		}
+		return err
+	}
+	return nil
+}
+
+// started records that the call at index is about to run.
+func (cp *runCheckpoint) started(ctx context.Context, index int) error {
+	return cp.save(ctx, func(s *RunState) {
+		s.Pending[index].Started = true
+	})
+}
+
+// finished records the result of c.
+func (cp *runCheckpoint) finished(ctx context.Context, c *toolCall) {
+	_ = cp.save(ctx, func(s *RunState) {
+		s.Pending[c.index] = c.pending()
+	})
+}
+
+// pending returns the checkpoint entry of c.
+func (c *toolCall) pending() PendingCall {
+	p := PendingCall{
+		Call:     c.call,
+		Started:  c.started,
+		Done:     c.done || c.finished,
+		Result:   c.result,
+		Parts:    c.parts,
+		Invalid:  c.invalid,
+		Rejected: c.rejected,
+	}
+	if c.err != nil {
+		p.Error = c.err.Error()
+	}
+	return p
+}
+
+// restoredCall recreates a call from a checkpoint, answering calls that were interrupted.
+func restoredCall(p PendingCall, tool llm_models.Tool) *toolCall {
+	c := &toolCall{call: p.Call, tool: tool, done: true, invalid: p.Invalid, rejected: p.Rejected}
+	if !p.Done {
+		c.err = ErrToolInterrupted
+		data, _ := json.Marshal(toolError{Error: c.err.Error(), Type: "interrupted", Tool: p.Call.Name})
+		c.result = string(data)
+		return c
+	}
+	c.result, c.parts = p.Result, p.Parts
+	if p.Error != "" {
+		c.err = errors.New(p.Error)
+	}
+	return c
+}
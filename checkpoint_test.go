@@ -0,0 +1,101 @@
+package llm_client
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingStore keeps every saved state.
+type recordingStore struct {
+	*MemoryCheckpointStore
+	saved []RunState
+}
+
+func (s *recordingStore) Save(ctx context.Context, state *RunState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	var copied RunState
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return err
+	}
+	s.saved = append(s.saved, copied)
+	return s.MemoryCheckpointStore.Save(ctx, state)
+}
+
+func TestResumeRunAfterCrash(t *testing.T) {
+	var charged []string
+	charge := idTool("charge", func(ctx context.Context, id string) string {
+		charged = append(charged, id)
+		return "charged " + id
+	})
+	tools := []llm_models.Tool{charge}
+	store := &recordingStore{MemoryCheckpointStore: NewMemoryCheckpointStore()}
+	client := &fakeClient{responses: []Response{callsTurn("charge", "a", "b"), {Content: "done"}}}
+	_, err := RunTools(context.Background(), client, []Message{{Role: RoleUser, Content: "charge both"}}, tools,
+		ToolLoopOptions{MaxCalls: 5, Checkpoint: store, RunID: "run-1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, charged)
+
+	// the process died while "b" was running
+	crashed := store.saved[3]
+	require.Len(t, crashed.Pending, 2)
+	assert.True(t, crashed.Pending[0].Done)
+	assert.True(t, crashed.Pending[1].Started)
+	assert.False(t, crashed.Pending[1].Done)
+	assert.Equal(t, 0, crashed.CallCount)
+
+	restarted := NewMemoryCheckpointStore()
+	require.NoError(t, restarted.Save(context.Background(), &crashed))
+	client = &fakeClient{responses: []Response{{Content: "b needs checking"}}}
+	res, err := ResumeRun(context.Background(), client, restarted, "run-1", tools, ToolLoopOptions{MaxCalls: 5})
+	require.NoError(t, err)
+	assert.Equal(t, "b needs checking", res.Answer)
+	assert.Equal(t, 2, res.CallCount)
+	// no tool ran twice
+	assert.Equal(t, []string{"a", "b"}, charged)
+
+	sent := client.calls[0]
+	require.Len(t, sent, 5)
+	assert.Equal(t, "charged a", sent[2].Content)
+	var interrupted toolError
+	require.NoError(t, json.Unmarshal([]byte(sent[4].Content), &interrupted))
+	assert.Equal(t, "interrupted", interrupted.Type)
+
+	// the resumed run keeps saving, a finished run is returned as it is
+	state, err := restarted.Load(context.Background(), "run-1")
+	require.NoError(t, err)
+	assert.Equal(t, StopFinalAnswer, state.StopReason)
+	res, err = ResumeRun(context.Background(), &fakeClient{}, restarted, "run-1", tools, ToolLoopOptions{MaxCalls: 5})
+	require.NoError(t, err)
+	assert.Equal(t, "b needs checking", res.Answer)
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	store := FileCheckpointStore{Dir: t.TempDir()}
+	ctx := context.Background()
+	state := &RunState{
+		ID:        "run-2",
+		Messages:  []Message{{Role: RoleUser, Content: "hi"}},
+		CallCount: 1,
+		Pending:   []PendingCall{{Call: &FunctionCall{ID: "1", Name: "charge", Arguments: "{}"}, Started: true}},
+	}
+	require.NoError(t, store.Save(ctx, state))
+	loaded, err := store.Load(ctx, "run-2")
+	require.NoError(t, err)
+	assert.Equal(t, state.Pending, loaded.Pending)
+	assert.Equal(t, state.Messages, loaded.Messages)
+
+	_, err = store.Load(ctx, "missing")
+	assert.ErrorIs(t, err, ErrRunNotFound)
+	assert.Error(t, store.Save(ctx, &RunState{ID: "../escape"}))
+
+	_, err = RunTools(ctx, &fakeClient{}, nil, nil, ToolLoopOptions{MaxCalls: 1, Checkpoint: store})
+	assert.Error(t, err)
+}
@@ -0,0 +1,226 @@
+// Command toolgen generates tool definitions at build time, so binaries do not need the source
+// tools.CreateDef reads through runtime.FuncForPC. Mark functions and structs with a
+// "//llm:tool" line in their comment and add to the package:
+//
+//	//go:generate go run github.com/HiroCloud/llm-client/cmd/toolgen
+//
+// It writes one JSON file per tool to -out and a Go file, -o, whose function, -func, returns the
+// tools bound to their functions, see tools.Generate. The definitions are created by a temporary
+// program importing the package, so tools must be exported and the package cannot be main.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// directive marks the declarations to generate tools for.
+const directive = "//llm:tool"
+
+// driverFile is the temporary program that imports the package and runs tools.Generate.
+const driverFile = "toolgen_driver.go"
+
+func main() {
+	out := flag.String("out", "toolsjson", "directory of the tool JSON files, inside the package")
+	file := flag.String("o", "tools_gen.go", "name of the generated Go file")
+	fn := flag.String("func", "Tools", "name of the generated function returning the tools")
+	flag.Parse()
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	if err := run(dir, *out, *file, *fn); err != nil {
+		log.Fatalf("toolgen: %v", err)
+	}
+}
+
+// run generates the tools of the package in dir.
+func run(dir, out, file, fn string) (err error) {
+	pkg, symbols, err := findTools(dir, file)
+	if err != nil {
+		return err
+	}
+	if len(symbols) == 0 {
+		return fmt.Errorf("no %s declarations in %s", directive, dir)
+	}
+	if pkg == "main" {
+		return fmt.Errorf("%s: package main cannot be imported, move the tools to another package", dir)
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	// a previously generated file embeds JSON files that may be gone, it is put back on failure
+	generated := filepath.Join(dir, file)
+	if previous, readErr := os.ReadFile(generated); readErr == nil {
+		if err := os.Remove(generated); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				_ = os.WriteFile(generated, previous, 0644)
+			}
+		}()
+	}
+
+	list := exec.Command("go", "list", "-f", "{{.ImportPath}}", ".")
+	list.Dir = dir
+	list.Stderr = os.Stderr
+	pkgPath, err := list.Output()
+	if err != nil {
+		return fmt.Errorf("finding import path: %w", err)
+	}
+
+	var buf bytes.Buffer
+	err = driverTemplate.Execute(&buf, map[string]interface{}{
+		"Package": pkg,
+		"PkgPath": strings.TrimSpace(string(pkgPath)),
+		"Dir":     filepath.ToSlash(absDir),
+		"JSONDir": filepath.ToSlash(out),
+		"File":    file,
+		"Func":    fn,
+		"Symbols": symbols,
+	})
+	if err != nil {
+		return err
+	}
+	// outside the package so nothing is left in it, run from dir to build with its module
+	tmp, err := os.MkdirTemp("", "toolgen")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	driver := filepath.Join(tmp, driverFile)
+	if err := os.WriteFile(driver, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("go", "run", driver)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("generating tools: %w", err)
+	}
+	return nil
+}
+
+// symbol is an annotated declaration, Value is the Go expression of the function or struct.
+type symbol struct {
+	Name  string
+	Value string
+}
+
+// findTools returns the package name in dir and its declarations marked with the directive,
+// skipping test files and the generated file. Tools must be exported to be used by the driver.
+func findTools(dir, generated string) (string, []symbol, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	fset := token.NewFileSet()
+	var symbols []symbol
+	var errs []error
+	for _, name := range bp.GoFiles {
+		if name == generated {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if !hasDirective(decl.Doc) {
+					continue
+				}
+				switch {
+				case !decl.Name.IsExported():
+					errs = append(errs, fmt.Errorf("%s: function %s must be exported to be a tool", fset.Position(decl.Pos()), decl.Name.Name))
+				case decl.Recv != nil:
+					errs = append(errs, fmt.Errorf("%s: method %s cannot be a tool", fset.Position(decl.Pos()), decl.Name.Name))
+				case decl.Type.TypeParams != nil:
+					errs = append(errs, fmt.Errorf("%s: generic function %s cannot be a tool", fset.Position(decl.Pos()), decl.Name.Name))
+				default:
+					symbols = append(symbols, symbol{Name: decl.Name.Name, Value: decl.Name.Name})
+				}
+			case *ast.GenDecl:
+				if decl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					if !hasDirective(typeSpec.Doc) && (len(decl.Specs) > 1 || !hasDirective(decl.Doc)) {
+						continue
+					}
+					if _, ok := typeSpec.Type.(*ast.StructType); !ok || typeSpec.TypeParams != nil {
+						errs = append(errs, fmt.Errorf("%s: only functions and non generic structs can be tools, not %s", fset.Position(typeSpec.Pos()), typeSpec.Name.Name))
+						continue
+					}
+					if !typeSpec.Name.IsExported() {
+						errs = append(errs, fmt.Errorf("%s: struct %s must be exported to be a tool", fset.Position(typeSpec.Pos()), typeSpec.Name.Name))
+						continue
+					}
+					symbols = append(symbols, symbol{Name: typeSpec.Name.Name, Value: typeSpec.Name.Name + "{}"})
+				}
+			}
+		}
+	}
+	return bp.Name, symbols, errors.Join(errs...)
+}
+
+func hasDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == directive {
+			return true
+		}
+	}
+	return false
+}
+
+var driverTemplate = template.Must(template.New("driver").Parse(`// Code generated by toolgen. DO NOT EDIT.
+
+package main
+
+import (
+	"log"
+
+	"github.com/HiroCloud/llm-client/tools"
+	target {{printf "%q" .PkgPath}}
+)
+
+func main() {
+	err := tools.Generate(tools.GenerateOptions{
+		Package: {{printf "%q" .Package}},
+		PkgPath: {{printf "%q" .PkgPath}},
+		Dir:     {{printf "%q" .Dir}},
+		JSONDir: {{printf "%q" .JSONDir}},
+		File:    {{printf "%q" .File}},
+		Func:    {{printf "%q" .Func}},
+	}, []tools.GenerateSymbol{
+{{- range .Symbols}}
+		{Name: {{printf "%q" .Name}}, Value: target.{{.Value}}},
+{{- end}}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+`))
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindTools(t *testing.T) {
+	pkg, symbols, err := findTools("testdata/people", "tools_gen.go")
+	require.NoError(t, err)
+	assert.Equal(t, "people", pkg)
+	assert.Equal(t, []symbol{
+		{Name: "Person", Value: "Person{}"},
+		{Name: "GetPerson", Value: "GetPerson"},
+		{Name: "Remind", Value: "Remind"},
+	}, symbols)
+}
+
+func TestFindToolsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	src := `package bad
+
+// Box holds a value
+//
+//llm:tool
+type Box[T any] struct{ Value T }
+
+type Counter struct{}
+
+// Inc increments
+//
+//llm:tool
+func (c *Counter) Inc() {}
+
+// reset resets
+//
+//llm:tool
+func reset() {}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.go"), []byte(src), 0644))
+	_, _, err := findTools(dir, "tools_gen.go")
+	assert.ErrorContains(t, err, "only functions and non generic structs can be tools, not Box")
+	assert.ErrorContains(t, err, "method Inc cannot be a tool")
+	assert.ErrorContains(t, err, "function reset must be exported to be a tool")
+}
+
+func TestRunMainPackage(t *testing.T) {
+	dir := t.TempDir()
+	src := `package main
+
+// Hello says hello
+//
+//llm:tool
+func Hello() string { return "hello" }
+
+func main() {}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0644))
+	assert.ErrorContains(t, run(dir, "toolsjson", "tools_gen.go", "Tools"), "package main cannot be imported")
+}
+
+// TestRun generates the tools of a copy of testdata/people, compares the Go file with the one
+// checked in and runs a test using it.
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test")
+	}
+	// inside the module so the copy can import it, named like the package as CreateDef
+	// looks functions up by import path
+	tmp, err := os.MkdirTemp("testdata", "run")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(tmp) })
+	dir := filepath.Join(tmp, "people")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	copyFile(t, "testdata/people/people.go", filepath.Join(dir, "people.go"))
+	// the package's tests are not built
+	broken := filepath.Join(dir, "broken_test.go")
+	require.NoError(t, os.WriteFile(broken, []byte("package people\n\nfunc TestMain() {}\n"), 0644))
+
+	require.NoError(t, run(dir, "toolsjson", "tools_gen.go", "Tools"))
+	require.NoError(t, os.Remove(broken))
+	expected, err := os.ReadFile("testdata/people/tools_gen.go")
+	require.NoError(t, err)
+	actual, err := os.ReadFile(filepath.Join(dir, "tools_gen.go"))
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{"people.go", "tools_gen.go", "toolsjson"}, names)
+	for _, name := range []string{"Person.json", "GetPerson.json", "Remind.json"} {
+		assert.FileExists(t, filepath.Join(dir, "toolsjson", name))
+	}
+
+	// regenerating replaces the previous file
+	require.NoError(t, run(dir, "toolsjson", "tools_gen.go", "Tools"))
+
+	copyFile(t, "testdata/people/generated_test.go.txt", filepath.Join(dir, "generated_test.go"))
+	cmd := exec.Command("go", "test", "-count=1", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func copyFile(t *testing.T, from, to string) {
+	data, err := os.ReadFile(from)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(to, data, 0644))
+}
@@ -0,0 +1,48 @@
+package people
+
+//go:generate go run github.com/HiroCloud/llm-client/cmd/toolgen
+
+import (
+	"context"
+	"fmt"
+)
+
+// Priority of a reminder.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	return [...]string{"low", "high"}[p]
+}
+
+// Person a person
+//
+//llm:tool
+type Person struct {
+	// name of the person
+	Name string `json:"name"`
+	// age of the person
+	Age int `json:"age"`
+}
+
+// GetPerson looks up a person by name
+//
+//llm:tool
+func GetPerson(ctx context.Context, name string) (Person, error) {
+	return Person{Name: name, Age: 42}, nil
+}
+
+// Remind reminds a person of something
+// priority: optional, default "low"
+//
+//llm:tool
+func Remind(name string, priority Priority) string {
+	return fmt.Sprintf("%s %d", name, priority)
+}
+
+// Forget is not a tool.
+func Forget(name string) {}
@@ -0,0 +1,43 @@
+// Code generated by toolgen. DO NOT EDIT.
+
+package people
+
+import (
+	_ "embed"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/HiroCloud/llm-client/tools"
+)
+
+//go:embed toolsjson/Person.json
+var toolgenPerson []byte
+
+//go:embed toolsjson/GetPerson.json
+var toolgenGetPerson []byte
+
+//go:embed toolsjson/Remind.json
+var toolgenRemind []byte
+
+func init() {
+	tools.RegisterEnum[Priority](0, 1)
+}
+
+// Tools returns the generated tools bound to their functions.
+func Tools() ([]llm_models.Tool, error) {
+	var list []llm_models.Tool
+	for _, g := range []struct {
+		value interface{}
+		data  []byte
+	}{
+		{Person{}, toolgenPerson},
+		{GetPerson, toolgenGetPerson},
+		{Remind, toolgenRemind},
+	} {
+		t, err := tools.NewToolFromGenerated(g.value, g.data)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+	return list, nil
+}
@@ -29,7 +29,7 @@ func main() {
 	// Parse command line arguments
 	filePath := flag.String("file", "", "Path to the Go file to analyze")
 	funcName := flag.String("func", "", "Name of the function to find")
-	replaceBody := flag.Bool("replacebody", false, "New body for the function to replace the existing one (e.g., 'return nil').")
+	flag.Bool("replacebody", false, "New body for the function to replace the existing one (e.g., 'return nil').")
 	flag.Parse()
 
 	if *filePath == "" || *funcName == "" {
@@ -0,0 +1,77 @@
+package llm_client
+
+import (
+	"context"
+	"fmt"
+)
+
+// EmbeddingTaskType hints the model at how the embeddings will be used.
+// Providers without task types (OpenAI) ignore it.
+type EmbeddingTaskType string
+
+const (
+	EmbeddingTaskRetrievalQuery     EmbeddingTaskType = "RETRIEVAL_QUERY"
+	EmbeddingTaskRetrievalDocument  EmbeddingTaskType = "RETRIEVAL_DOCUMENT"
+	EmbeddingTaskSemanticSimilarity EmbeddingTaskType = "SEMANTIC_SIMILARITY"
+	EmbeddingTaskClassification     EmbeddingTaskType = "CLASSIFICATION"
+	EmbeddingTaskClustering         EmbeddingTaskType = "CLUSTERING"
+)
+
+// Default embedding models used when EmbeddingRequest.Model is empty.
+const (
+	DefaultOpenAIEmbeddingModel = "text-embedding-3-small"
+	DefaultGoogleEmbeddingModel = "gemini-embedding-001"
+)
+
+// EmbeddingRequest asks for one embedding vector per input text.
+type EmbeddingRequest struct {
+	Model      string            // Embedding model name/ID (provider default if empty)
+	Input      []string          // Texts to embed
+	Dimensions int               // Optional output dimensionality (0 = model default)
+	TaskType   EmbeddingTaskType // Optional task type hint
+	BatchSize  int               // Max inputs per API call (0 = provider limit)
+}
+
+// EmbeddingResponse holds the vectors in the same order as EmbeddingRequest.Input.
+type EmbeddingResponse struct {
+	Embeddings [][]float32 // One vector per input
+	Usage      TokenUsage  // Token usage summed over all API calls (if reported)
+}
+
+// Embedder is implemented by clients that can create embeddings.
+type Embedder interface {
+	Embed(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error)
+}
+
+// embedBatches splits req.Input into chunks of at most batchSize (or req.BatchSize if smaller)
+// and concatenates the results of embed, summing the usage.
+func embedBatches(
+	ctx context.Context,
+	req EmbeddingRequest,
+	batchSize int,
+	embed func(ctx context.Context, input []string) (EmbeddingResponse, error),
+) (EmbeddingResponse, error) {
+	if len(req.Input) == 0 {
+		return EmbeddingResponse{}, fmt.Errorf("embedding request without input")
+	}
+	if req.BatchSize > 0 && req.BatchSize < batchSize {
+		batchSize = req.BatchSize
+	}
+
+	out := EmbeddingResponse{Embeddings: make([][]float32, 0, len(req.Input))}
+	for start := 0; start < len(req.Input); start += batchSize {
+		end := min(start+batchSize, len(req.Input))
+		resp, err := embed(ctx, req.Input[start:end])
+		if err != nil {
+			return EmbeddingResponse{}, fmt.Errorf("embedding inputs %d-%d: %w", start, end-1, err)
+		}
+		if len(resp.Embeddings) != end-start {
+			return EmbeddingResponse{}, fmt.Errorf("expected %d embeddings, got %d", end-start, len(resp.Embeddings))
+		}
+		out.Embeddings = append(out.Embeddings, resp.Embeddings...)
+		out.Usage.PromptTokens += resp.Usage.PromptTokens
+		out.Usage.CompletionTokens += resp.Usage.CompletionTokens
+		out.Usage.TotalTokens += resp.Usage.TotalTokens
+	}
+	return out, nil
+}
@@ -0,0 +1,106 @@
+package llm_client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	genai "google.golang.org/genai"
+)
+
+func TestOpenAIEmbed(t *testing.T) {
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.EmbeddingRequestStrings
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, openai.EmbeddingModel(DefaultOpenAIEmbeddingModel), req.Model)
+		assert.Equal(t, 3, req.Dimensions)
+		batches = append(batches, req.Input)
+		resp := openai.EmbeddingResponse{Usage: openai.Usage{PromptTokens: len(req.Input), TotalTokens: len(req.Input)}}
+		// answer out of order, the client must sort by index
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			resp.Data = append(resp.Data, openai.Embedding{Index: i, Embedding: []float32{float32(len(req.Input[i])), 0, 0}})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	config := openai.DefaultConfig("test")
+	config.BaseURL = server.URL + "/v1"
+	client := NewOpenAIClientFromConfig(config, "gpt-4o-mini")
+
+	resp, err := client.Embed(context.Background(), EmbeddingRequest{
+		Input:      []string{"a", "bb", "ccc"},
+		Dimensions: 3,
+		BatchSize:  2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "bb"}, {"ccc"}}, batches)
+	assert.Equal(t, [][]float32{{1, 0, 0}, {2, 0, 0}, {3, 0, 0}}, resp.Embeddings)
+	assert.Equal(t, 3, resp.Usage.TotalTokens)
+
+	_, err = client.Embed(context.Background(), EmbeddingRequest{})
+	assert.Error(t, err)
+}
+
+func TestOpenAIEmbedMissingIndex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// only the first input gets an embedding
+		resp := openai.EmbeddingResponse{Data: []openai.Embedding{{Index: 0, Embedding: []float32{1}}}}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	config := openai.DefaultConfig("test")
+	config.BaseURL = server.URL + "/v1"
+	client := NewOpenAIClientFromConfig(config, "gpt-4o-mini")
+
+	_, err := client.Embed(context.Background(), EmbeddingRequest{Input: []string{"a", "bb"}})
+	assert.ErrorContains(t, err, "no embedding for input 1")
+}
+
+func TestGoogleEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1beta/models/"+DefaultGoogleEmbeddingModel+":batchEmbedContents", r.URL.Path)
+		var body struct {
+			Requests []map[string]any `json:"requests"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		var embeddings []map[string]any
+		for i, req := range body.Requests {
+			assert.Equal(t, "RETRIEVAL_DOCUMENT", req["taskType"])
+			assert.EqualValues(t, 2, req["outputDimensionality"])
+			embeddings = append(embeddings, map[string]any{"values": []float32{float32(i), 1}})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"embeddings": embeddings})
+	}))
+	defer server.Close()
+
+	client, err := NewGoogleClient(context.Background(), &genai.ClientConfig{
+		APIKey:      "test",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	}, "gemini-test")
+	require.NoError(t, err)
+
+	var input []string
+	for i := 0; i < 150; i++ {
+		input = append(input, fmt.Sprintf("doc %d", i))
+	}
+	resp, err := client.Embed(context.Background(), EmbeddingRequest{
+		Input:      input,
+		Dimensions: 2,
+		TaskType:   EmbeddingTaskRetrievalDocument,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Embeddings, 150)
+	// 150 inputs are split in batches of 100 and 50
+	assert.Equal(t, []float32{99, 1}, resp.Embeddings[99])
+	assert.Equal(t, []float32{0, 1}, resp.Embeddings[100])
+}
@@ -0,0 +1,52 @@
+package llm_client
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+)
+
+// fakeClient is a scriptable AIClient used by the package tests.
+type fakeClient struct {
+	mu        sync.Mutex
+	chat      func(ctx context.Context, req ChatRequest) (ChatResponse, error)
+	responses []Response // returned by GenerateResponse in order
+	calls     [][]Message
+}
+
+func (f *fakeClient) ChatCompletion(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if f.chat == nil {
+		return ChatResponse{}, errors.New("not implemented")
+	}
+	return f.chat(ctx, req)
+}
+
+func (f *fakeClient) ChatCompletionStream(ctx context.Context, req ChatRequest) (ChatStream, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeClient) TextCompletion(ctx context.Context, req TextRequest) (TextResponse, error) {
+	return TextResponse{}, errors.New("not implemented")
+}
+
+func (f *fakeClient) TextCompletionStream(ctx context.Context, req TextRequest) (TextStream, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeClient) GenerateImage(ctx context.Context, req ImageRequest) (ImageResponse, error) {
+	return ImageResponse{}, errors.New("not implemented")
+}
+
+func (f *fakeClient) GenerateResponse(ctx context.Context, messages []Message, tools []llm_models.Tool) (Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, append([]Message(nil), messages...))
+	if len(f.responses) == 0 {
+		return Response{}, errors.New("no scripted response left")
+	}
+	resp := f.responses[0]
+	f.responses = f.responses[1:]
+	return resp, nil
+}
@@ -2,11 +2,15 @@ package llm_client
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"iter"
+	"mime"
 	"os"
+	"path"
+	"strings"
 
 	"github.com/HiroCloud/llm-client/llm_models"
 	genai "google.golang.org/genai"
@@ -14,23 +18,37 @@ import (
 
 // GoogleClient implements AIClient for Google Gemini/Vertex AI models.
 type GoogleClient struct {
-	client       *genai.Client // underlying GenAI client (configured for Gemini or Vertex AI)
-	defaultModel string        // default model name/ID to use if none specified
+	client       *genai.Client  // underlying GenAI client (configured for Gemini or Vertex AI)
+	defaultModel string         // default model name/ID to use if none specified
+	models       *ModelRegistry // requests are validated against it, nil disables validation
 }
 
+// googleDefaultImageModel is used by GenerateImage when neither the request nor the
+// client's default model can generate images.
+const googleDefaultImageModel = "imagen-4.0-generate-001"
+
 func NewGC() (AIClient, error) {
 	apiKey := os.Getenv("GEMINI_API_KEY")
 	if apiKey == "" {
 		return nil, fmt.Errorf("GEMINI_API_KEY not set")
 	}
 
-	ctx := context.Background()
-
 	// create the client
 	// 💡 FIX: The NewClient function takes a context AND an option list.
 	// The API key is passed using option.WithAPIKey()
 	cc := &genai.ClientConfig{}
 	cc.APIKey = apiKey
+	// Recommending the latest flash model
+	c, err := NewGoogleClient(context.Background(), cc, "gemini-3-flash-preview")
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewGoogleClient creates a GoogleClient from a GenAI client config.
+// defaultModel is used whenever a request does not name a model.
+func NewGoogleClient(ctx context.Context, cc *genai.ClientConfig, defaultModel string) (*GoogleClient, error) {
 	c, err := genai.NewClient(ctx, cc)
 	if err != nil {
 		return nil, err
@@ -38,41 +56,99 @@ func NewGC() (AIClient, error) {
 
 	return &GoogleClient{
 		client:       c,
-		defaultModel: "gemini-3-flash-preview", // Recommending the latest flash model
+		defaultModel: defaultModel,
+		models:       DefaultModels,
 	}, nil
 }
 
+// SetModelRegistry replaces the registry requests are validated against, nil disables validation.
+func (c *GoogleClient) SetModelRegistry(models *ModelRegistry) {
+	c.models = models
+}
+
 // --- Chat Completion (Google Gemini) ---
 func (c *GoogleClient) ChatCompletion(ctx context.Context, req ChatRequest) (ChatResponse, error) {
 	model := req.Model
 	if model == "" {
 		model = c.defaultModel
 	}
-	// Convert Messages to genai.Content parts.
+	req.Model = model
+	if err := c.models.ValidateChat(req); err != nil {
+		return ChatResponse{}, err
+	}
+	// Call Google's content generation API (for chat or prompt completion)
+	result, err := c.client.Models.GenerateContent(ctx, model, googleContents(req.Messages), googleGenConfig(req))
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	return googleChatResponse(result)
+}
+
+// googleContents converts Messages to genai.Content parts.
+func googleContents(messages []Message) []*genai.Content {
 	// We provide the conversation as a sequence of parts (text segments).
 	// Since the Gemini API doesn't have explicit roles in the request, we concatenate
 	// system and user messages in order as input parts. (Assistant responses in history
 	// can also be included as context.)
 	var contentParts []*genai.Part
-	for _, m := range req.Messages {
+	for _, m := range messages {
 		// We only include user/system content as prompt context for the model.
 		// (The assistant's prior messages should also be included as context if present.)
 		part := genai.NewPartFromText(m.Content)
 		contentParts = append(contentParts, part)
+		for _, p := range m.MultiContent {
+			contentParts = append(contentParts, googlePart(p))
+		}
 	}
-	contents := []*genai.Content{{Parts: contentParts}}
-	// Prepare config with generation parameters
-	genConfig := &genai.GenerateContentConfig{
-		Temperature:     genai.Ptr(float32(req.Options.Temperature)),
-		TopP:            genai.Ptr(float32(req.Options.TopP)),
-		MaxOutputTokens: int32(req.Options.MaxTokens),
+	return []*genai.Content{{Parts: contentParts}}
+}
+
+// googlePart converts a text or image message part. Images given as data URL are sent inline,
+// other URLs as file references.
+func googlePart(p llm_models.ChatMessagePart) *genai.Part {
+	if p.ImageURL == nil {
+		return genai.NewPartFromText(p.Text)
+	}
+	url := p.ImageURL.URL
+	if rest, ok := strings.CutPrefix(url, "data:"); ok {
+		if meta, data, ok := strings.Cut(rest, ","); ok {
+			mimeType, isBase64 := strings.CutSuffix(meta, ";base64")
+			if decoded, err := base64.StdEncoding.DecodeString(data); isBase64 && err == nil {
+				return genai.NewPartFromBytes(decoded, mimeType)
+			}
+		}
 	}
-	// Call Google's content generation API (for chat or prompt completion)
-	result, err := c.client.Models.GenerateContent(ctx, model, contents, genConfig)
-	if err != nil {
-		return ChatResponse{}, err
+	mimeType := mime.TypeByExtension(path.Ext(url))
+	if mimeType == "" {
+		mimeType = "image/jpeg"
 	}
-	// Map GenerateContentResponse to ChatResponse
+	return genai.NewPartFromURI(url, mimeType)
+}
+
+// googleGenConfig prepares config with generation parameters and the functions of req
+func googleGenConfig(req ChatRequest) *genai.GenerateContentConfig {
+	opts := req.Options
+	config := &genai.GenerateContentConfig{
+		Temperature:     genai.Ptr(float32(opts.Temperature)),
+		TopP:            genai.Ptr(float32(opts.TopP)),
+		MaxOutputTokens: int32(opts.MaxTokens),
+	}
+	if len(req.Functions) > 0 {
+		decls := make([]*genai.FunctionDeclaration, len(req.Functions))
+		for i, fn := range req.Functions {
+			decls[i] = &genai.FunctionDeclaration{
+				Name:                 fn.Name,
+				Description:          fn.Description,
+				ParametersJsonSchema: fn.Parameters,
+			}
+		}
+		config.Tools = []*genai.Tool{{FunctionDeclarations: decls}}
+	}
+	return config
+}
+
+// googleChatResponse maps GenerateContentResponse to ChatResponse
+func googleChatResponse(result *genai.GenerateContentResponse) (ChatResponse, error) {
 	var out ChatResponse
 	if usage := result.UsageMetadata; usage != nil {
 		out.Usage = TokenUsage{
@@ -117,17 +193,11 @@ func (c *GoogleClient) ChatCompletionStream(ctx context.Context, req ChatRequest
 	if model == "" {
 		model = c.defaultModel
 	}
-	var contentParts []*genai.Part
-	for _, m := range req.Messages {
-		contentParts = append(contentParts, genai.NewPartFromText(m.Content))
-	}
-	contents := []*genai.Content{{Parts: contentParts}}
-	genConfig := &genai.GenerateContentConfig{
-		Temperature:     genai.Ptr(float32(req.Options.Temperature)),
-		TopP:            genai.Ptr(float32(req.Options.TopP)),
-		MaxOutputTokens: int32(req.Options.MaxTokens),
+	req.Model = model
+	if err := c.models.ValidateChat(req); err != nil {
+		return nil, err
 	}
-	streamIter := c.client.Models.GenerateContentStream(ctx, model, contents, genConfig)
+	streamIter := c.client.Models.GenerateContentStream(ctx, model, googleContents(req.Messages), googleGenConfig(req))
 	next, stop := iter.Pull2(streamIter)
 	return &googleChatStream{next: next, stop: stop, ctx: ctx}, nil
 }
@@ -184,6 +254,10 @@ func (c *GoogleClient) TextCompletion(ctx context.Context, req TextRequest) (Tex
 	if model == "" {
 		model = c.defaultModel
 	}
+	req.Model = model
+	if err := c.models.ValidateText(req); err != nil {
+		return TextResponse{}, err
+	}
 	parts := []*genai.Part{genai.NewPartFromText(req.Prompt)}
 	contents := []*genai.Content{{Parts: parts}}
 	genConfig := &genai.GenerateContentConfig{
@@ -223,6 +297,10 @@ func (c *GoogleClient) TextCompletionStream(ctx context.Context, req TextRequest
 	if model == "" {
 		model = c.defaultModel
 	}
+	req.Model = model
+	if err := c.models.ValidateText(req); err != nil {
+		return nil, err
+	}
 	contents := []*genai.Content{{Parts: []*genai.Part{genai.NewPartFromText(req.Prompt)}}}
 	genConfig := &genai.GenerateContentConfig{
 		Temperature:     genai.Ptr(float32(req.Options.Temperature)),
@@ -267,6 +345,13 @@ func (c *GoogleClient) GenerateImage(ctx context.Context, req ImageRequest) (Ima
 	model := req.Model
 	if model == "" {
 		model = c.defaultModel
+		if !c.models.supports(model, llm_models.ModelTypeImage) {
+			model = googleDefaultImageModel
+		}
+	}
+	req.Model = model
+	if err := c.models.ValidateImage(req); err != nil {
+		return ImageResponse{}, err
 	}
 	genConfig := &genai.GenerateImagesConfig{
 		NumberOfImages: int32(req.N),
@@ -301,10 +386,15 @@ func (c *GoogleClient) GenerateResponse(
 	// 1) Convert Tool → FuncDef (the JSON schema part only)
 	funcDefs := make([]FunctionDef, len(tools))
 	for i, t := range tools {
+		params, err := t.Function.JSONSchema()
+		if err != nil {
+			return Response{}, err
+		}
 		funcDefs[i] = FunctionDef{
 			Name:        t.Function.Name,
 			Description: t.Function.Description,
-			Parameters:  t.Function.Parameters,
+			// Gemini function declarations do not resolve $ref
+			Parameters: llm_models.InlineRefs(params),
 		}
 	}
 
@@ -333,3 +423,39 @@ func (c *GoogleClient) GenerateResponse(
 	}
 	return out, nil
 }
+
+// --- Embeddings (Google Gemini) ---
+
+// googleEmbeddingBatchSize is the maximum number of inputs accepted by a single embed call.
+const googleEmbeddingBatchSize = 100
+
+func (c *GoogleClient) Embed(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = DefaultGoogleEmbeddingModel
+	}
+	config := &genai.EmbedContentConfig{TaskType: string(req.TaskType)}
+	if req.Dimensions > 0 {
+		config.OutputDimensionality = genai.Ptr(int32(req.Dimensions))
+	}
+	return embedBatches(ctx, req, googleEmbeddingBatchSize, func(ctx context.Context, input []string) (EmbeddingResponse, error) {
+		contents := make([]*genai.Content, len(input))
+		for i, text := range input {
+			contents[i] = genai.NewContentFromText(text, genai.RoleUser)
+		}
+		result, err := c.client.Models.EmbedContent(ctx, model, contents, config)
+		if err != nil {
+			return EmbeddingResponse{}, err
+		}
+		var out EmbeddingResponse
+		for _, e := range result.Embeddings {
+			out.Embeddings = append(out.Embeddings, e.Values)
+			// token statistics are only reported by Vertex AI
+			if e.Statistics != nil {
+				out.Usage.PromptTokens += int(e.Statistics.TokenCount)
+			}
+		}
+		out.Usage.TotalTokens = out.Usage.PromptTokens
+		return out, nil
+	})
+}
@@ -0,0 +1,230 @@
+package llm_client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	genai "google.golang.org/genai"
+)
+
+// geminiBatchCustomID is the inlined request metadata key that carries BatchItem.CustomID.
+const geminiBatchCustomID = "custom_id"
+
+// geminiBatchLine is a single line of a Gemini batch JSONL input or output file.
+type geminiBatchLine struct {
+	Key      string                         `json:"key"`
+	Request  *geminiBatchRequest            `json:"request,omitempty"`
+	Response *genai.GenerateContentResponse `json:"response,omitempty"`
+	Error    *genai.JobError                `json:"error,omitempty"`
+}
+
+type geminiBatchRequest struct {
+	Contents         []*genai.Content             `json:"contents"`
+	GenerationConfig *genai.GenerateContentConfig `json:"generationConfig,omitempty"`
+	Tools            []*genai.Tool                `json:"tools,omitempty"`
+}
+
+// geminiBatchModel returns the single model shared by all items, Gemini batches run on one model.
+func (c *GoogleClient) geminiBatchModel(items []BatchItem) (string, error) {
+	model := ""
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item.CustomID == "" {
+			return "", fmt.Errorf("batch item without custom id")
+		}
+		if seen[item.CustomID] {
+			return "", fmt.Errorf("duplicate batch custom id %q", item.CustomID)
+		}
+		seen[item.CustomID] = true
+		m := item.Request.Model
+		if m == "" {
+			m = c.defaultModel
+		}
+		if model != "" && m != model {
+			return "", fmt.Errorf("gemini batches require a single model, got %s and %s", model, m)
+		}
+		model = m
+	}
+	return model, nil
+}
+
+// WriteBatchFile writes the Gemini batch JSONL input file for items to w,
+// using the custom id as the line key.
+func (c *GoogleClient) WriteBatchFile(w io.Writer, items []BatchItem) error {
+	if _, err := c.geminiBatchModel(items); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	for _, item := range items {
+		// tools are a field of the request in the file, not of the generation config
+		config := googleGenConfig(item.Request)
+		tools := config.Tools
+		config.Tools = nil
+		line := geminiBatchLine{
+			Key: item.CustomID,
+			Request: &geminiBatchRequest{
+				Contents:         googleContents(item.Request.Messages),
+				GenerationConfig: config,
+				Tools:            tools,
+			},
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubmitBatch starts a Gemini batch job with the items inlined in the request.
+func (c *GoogleClient) SubmitBatch(ctx context.Context, items []BatchItem) (BatchJob, error) {
+	model, err := c.geminiBatchModel(items)
+	if err != nil {
+		return BatchJob{}, err
+	}
+	src := &genai.BatchJobSource{}
+	for _, item := range items {
+		src.InlinedRequests = append(src.InlinedRequests, &genai.InlinedRequest{
+			Contents: googleContents(item.Request.Messages),
+			Config:   googleGenConfig(item.Request),
+			Metadata: map[string]string{geminiBatchCustomID: item.CustomID},
+		})
+	}
+	job, err := c.client.Batches.Create(ctx, model, src, nil)
+	if err != nil {
+		return BatchJob{}, err
+	}
+	return googleBatchJob(job), nil
+}
+
+// SubmitBatchFile uploads items as a JSONL file and starts a Gemini batch job reading from it,
+// which is required once a batch is too large to be inlined.
+func (c *GoogleClient) SubmitBatchFile(ctx context.Context, items []BatchItem) (BatchJob, error) {
+	model, err := c.geminiBatchModel(items)
+	if err != nil {
+		return BatchJob{}, err
+	}
+	var buf bytes.Buffer
+	if err := c.WriteBatchFile(&buf, items); err != nil {
+		return BatchJob{}, err
+	}
+	file, err := c.client.Files.Upload(ctx, &buf, &genai.UploadFileConfig{MIMEType: "jsonl"})
+	if err != nil {
+		return BatchJob{}, fmt.Errorf("upload batch file: %w", err)
+	}
+	job, err := c.client.Batches.Create(ctx, model, &genai.BatchJobSource{FileName: file.Name}, nil)
+	if err != nil {
+		return BatchJob{}, err
+	}
+	return googleBatchJob(job), nil
+}
+
+// GetBatch returns the current state of a Gemini batch job.
+func (c *GoogleClient) GetBatch(ctx context.Context, id string) (BatchJob, error) {
+	job, err := c.client.Batches.Get(ctx, id, nil)
+	if err != nil {
+		return BatchJob{}, err
+	}
+	return googleBatchJob(job), nil
+}
+
+// CancelBatch cancels a Gemini batch job.
+func (c *GoogleClient) CancelBatch(ctx context.Context, id string) error {
+	return c.client.Batches.Cancel(ctx, id, nil)
+}
+
+// BatchResults returns the results of a finished Gemini batch job, either from the inlined
+// responses or by downloading the responses file.
+func (c *GoogleClient) BatchResults(ctx context.Context, id string) (map[string]BatchItemResult, error) {
+	job, err := c.client.Batches.Get(ctx, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	if job.Dest == nil {
+		return nil, fmt.Errorf("batch %s has no results (state %s)", id, job.State)
+	}
+	results := make(map[string]BatchItemResult)
+	if job.Dest.FileName != "" {
+		data, err := c.client.Files.Download(ctx, genai.NewDownloadURIFromFile(&genai.File{Name: job.Dest.FileName}), nil)
+		if err != nil {
+			return nil, fmt.Errorf("download batch file %s: %w", job.Dest.FileName, err)
+		}
+		if err := parseGeminiBatchOutput(data, results); err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+	for i, inlined := range job.Dest.InlinedResponses {
+		// responses keep the input order, the index is only used if the metadata was dropped
+		customID := inlined.Metadata[geminiBatchCustomID]
+		if customID == "" {
+			customID = strconv.Itoa(i)
+		}
+		results[customID] = geminiBatchResult(customID, inlined.Response, inlined.Error)
+	}
+	return results, nil
+}
+
+// parseGeminiBatchOutput maps the lines of a Gemini batch responses file to results by key.
+func parseGeminiBatchOutput(data []byte, results map[string]BatchItemResult) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line geminiBatchLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return fmt.Errorf("invalid batch output line: %w", err)
+		}
+		results[line.Key] = geminiBatchResult(line.Key, line.Response, line.Error)
+	}
+	return scanner.Err()
+}
+
+func geminiBatchResult(customID string, resp *genai.GenerateContentResponse, jobErr *genai.JobError) BatchItemResult {
+	res := BatchItemResult{CustomID: customID}
+	switch {
+	case jobErr != nil:
+		res.Err = fmt.Errorf("batch request failed: %s", jobErr.Message)
+	case resp == nil:
+		res.Err = fmt.Errorf("batch request returned no response")
+	default:
+		res.Response, res.Err = googleChatResponse(resp)
+	}
+	return res
+}
+
+func googleBatchJob(job *genai.BatchJob) BatchJob {
+	out := BatchJob{
+		ID:             job.Name,
+		ProviderStatus: string(job.State),
+	}
+	if stats := job.CompletionStats; stats != nil {
+		out.Completed = int(stats.SuccessfulCount)
+		out.Failed = int(stats.FailedCount)
+		out.Total = out.Completed + out.Failed
+		if stats.IncompleteCount > 0 {
+			out.Total += int(stats.IncompleteCount)
+		}
+	}
+	switch job.State {
+	case genai.JobStateSucceeded, genai.JobStatePartiallySucceeded:
+		out.Status = BatchJobStatusSucceeded
+	case genai.JobStateFailed:
+		out.Status = BatchJobStatusFailed
+	case genai.JobStateCancelled:
+		out.Status = BatchJobStatusCancelled
+	case genai.JobStateExpired:
+		out.Status = BatchJobStatusExpired
+	case genai.JobStateRunning, genai.JobStateCancelling, genai.JobStateUpdating:
+		out.Status = BatchJobStatusRunning
+	default:
+		out.Status = BatchJobStatusPending
+	}
+	return out
+}
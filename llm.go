@@ -19,6 +19,7 @@ type Message struct {
 	Content      string // The text content of the message
 	Name         string // Optional name (e.g. function name if Role=="function")
 	FunctionCall *FunctionCall
+	MultiContent []llm_models.ChatMessagePart // Optional images or other parts sent along with Content
 }
 
 // FunctionDef describes a function for the model to potentially call.
@@ -60,3 +60,11 @@ const (
 	ChatCompletionResponseFormatTypeJSONSchema ChatCompletionResponseFormatType = "json_schema"
 	ChatCompletionResponseFormatTypeText       ChatCompletionResponseFormatType = "text"
 )
+
+// ApprovalPolicy tells whether calls of a tool must be approved before they run.
+type ApprovalPolicy string
+
+const (
+	ApprovalAlways ApprovalPolicy = "always" // every call needs approval
+	ApprovalNever  ApprovalPolicy = "never"  // calls never need approval, even with an ApprovalFunc
+)
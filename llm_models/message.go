@@ -1,6 +1,10 @@
 package llm_models
 
-import "github.com/sashabaranov/go-openai/jsonschema"
+import (
+	"time"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
 
 // Message represents a communication structure containing name, content, role, refusal state, tools, and a tool call ID.
 type Message struct {
@@ -37,6 +41,15 @@ type Tool struct {
 	CallFunc    interface{}         `json:"-"` // some go func
 	ExitFunc    bool                `json:"exit_func"`
 	WriteToChat PromptStreamCommand `json:"write_to_chat"`
+	Sequential  bool                `json:"sequential,omitempty"` // never run concurrently with other calls
+	Timeout     time.Duration       `json:"-"`                    // maximum run time of one call, 0 means no limit, JSON as "5s"
+	// ResultFormatter, if set, converts the values returned by CallFunc (without the trailing error)
+	// into the text sent to the model instead of the default JSON encoding
+	ResultFormatter func(values []interface{}) (string, error) `json:"-"`
+	// Approval controls whether calls need a human OK before they run
+	Approval ApprovalPolicy `json:"approval,omitempty"`
+	// ApprovalFunc decides per call when Approval is not set, arguments is nil if they cannot be parsed
+	ApprovalFunc func(arguments map[string]interface{}) bool `json:"-"`
 }
 
 type FuncDef struct {
@@ -46,6 +59,14 @@ type FuncDef struct {
 	ParamOrder  []string              `json:"param_order,omitempty"`
 	Parameters  jsonschema.Definition `json:"parameters"`
 	Arguments   string                `json:"arguments,omitempty"`
+	// Defaults holds the values used for optional parameters the model leaves out,
+	// parameters without a default receive their zero value
+	Defaults map[string]interface{} `json:"defaults,omitempty"`
+	// StructArgs means the function takes a single struct and the parameters are its fields,
+	// the arguments object is decoded into that struct
+	StructArgs bool `json:"struct_args,omitempty"`
+	// Constraints holds additional JSON schema keywords per property path, see JSONSchema
+	Constraints map[string]SchemaConstraints `json:"constraints,omitempty"`
 }
 
 type ModelRequestConfig struct {
@@ -1 +1,194 @@
 package llm_models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// toolJSON is the JSON form of a Tool, the timeout is written as a duration string like "5s".
+type toolJSON struct {
+	toolFields
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// toolFields has the fields of Tool without its methods.
+type toolFields Tool
+
+// MarshalJSON writes the tool with its Timeout as a duration string.
+func (t Tool) MarshalJSON() ([]byte, error) {
+	out := toolJSON{toolFields: toolFields(t)}
+	if t.Timeout > 0 {
+		out.Timeout = t.Timeout.String()
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads a tool, the timeout may be a duration string or a number of nanoseconds.
+func (t *Tool) UnmarshalJSON(data []byte) error {
+	var in struct {
+		toolFields
+		Timeout json.RawMessage `json:"timeout,omitempty"`
+	}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*t = Tool(in.toolFields)
+	if len(in.Timeout) == 0 || string(in.Timeout) == "null" {
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(in.Timeout, &text); err != nil {
+		var nanos int64
+		if err := json.Unmarshal(in.Timeout, &nanos); err != nil {
+			return fmt.Errorf("invalid tool timeout %s", in.Timeout)
+		}
+		t.Timeout = time.Duration(nanos)
+		return nil
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		return fmt.Errorf("invalid tool timeout: %w", err)
+	}
+	t.Timeout = d
+	return nil
+}
+
+// SchemaConstraints holds JSON schema keywords jsonschema.Definition cannot express.
+// FuncDef.Constraints maps property paths to them, see FuncDef.JSONSchema.
+type SchemaConstraints struct {
+	Default    interface{}   `json:"default,omitempty"`
+	Minimum    *float64      `json:"minimum,omitempty"`
+	Maximum    *float64      `json:"maximum,omitempty"`
+	MinLength  *int          `json:"minLength,omitempty"`
+	MaxLength  *int          `json:"maxLength,omitempty"`
+	Pattern    string        `json:"pattern,omitempty"`
+	Format     string        `json:"format,omitempty"`
+	Examples   []interface{} `json:"examples,omitempty"`
+	Deprecated bool          `json:"deprecated,omitempty"`
+}
+
+// JSONSchema returns the parameters schema sent to providers: Parameters with the Constraints
+// and Defaults applied. Constraint paths are property names joined by ".", "[]" selects the
+// items of an array, "{}" the values of a map and "$defs.name" a shared definition, e.g.
+// "address.city", "tags[]" or "$defs.Node.children[]".
+func (f FuncDef) JSONSchema() (map[string]interface{}, error) {
+	params := f.Parameters
+	data, err := json.Marshal(&params)
+	if err != nil {
+		return nil, err
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+	for path, c := range f.Constraints {
+		node := SchemaNode(schema, path)
+		if node == nil {
+			return nil, fmt.Errorf("%s: constraints for unknown property %s", f.Name, path)
+		}
+		data, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &node); err != nil {
+			return nil, err
+		}
+	}
+	for name, value := range f.Defaults {
+		if node := SchemaNode(schema, name); node != nil {
+			node["default"] = value
+		}
+	}
+	return schema, nil
+}
+
+// SchemaNode returns the sub schema of a decoded JSON schema at path, see FuncDef.JSONSchema,
+// or nil if it does not exist.
+func SchemaNode(schema map[string]interface{}, path string) map[string]interface{} {
+	node := schema
+	if path == "" {
+		return node
+	}
+	segments := strings.Split(path, ".")
+	for i := 0; i < len(segments); i++ {
+		segment, key := segments[i], "properties"
+		if segment == "$defs" && i+1 < len(segments) {
+			i++
+			segment, key = segments[i], "$defs"
+		}
+		name := strings.TrimRight(segment, "[]{}")
+		if name != "" {
+			children, _ := node[key].(map[string]interface{})
+			node, _ = children[name].(map[string]interface{})
+		}
+		for rest := segment[len(name):]; rest != "" && node != nil; rest = rest[2:] {
+			key := "items"
+			if strings.HasPrefix(rest, "{}") {
+				key = "additionalProperties"
+			}
+			node, _ = node[key].(map[string]interface{})
+		}
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// InlineRefs returns a copy of a decoded JSON schema without $defs, for targets that do not
+// resolve references. Every "#" and "#/$defs/name" reference is replaced by the schema it points
+// to, keywords next to the reference win. A reference back into a definition being expanded is
+// cut off as a plain object.
+func InlineRefs(schema map[string]interface{}) map[string]interface{} {
+	defs, _ := schema["$defs"].(map[string]interface{})
+	root := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		if k != "$defs" {
+			root[k] = v
+		}
+	}
+	return inlineNode(root, root, defs, map[string]bool{}).(map[string]interface{})
+}
+
+func inlineNode(v interface{}, root, defs map[string]interface{}, expanding map[string]bool) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = inlineNode(item, root, defs, expanding)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		ref, _ := v["$ref"].(string)
+		if ref != "" {
+			var target map[string]interface{}
+			if ref == "#" {
+				target = root
+			} else if name, ok := strings.CutPrefix(ref, "#/$defs/"); ok {
+				target, _ = defs[name].(map[string]interface{})
+			}
+			switch {
+			case expanding[ref]:
+				out["type"] = "object"
+			case target != nil:
+				expanding[ref] = true
+				for k, tv := range inlineNode(target, root, defs, expanding).(map[string]interface{}) {
+					out[k] = tv
+				}
+				delete(expanding, ref)
+			default:
+				out["$ref"] = ref
+			}
+		}
+		for k, child := range v {
+			if k != "$ref" {
+				out[k] = inlineNode(child, root, defs, expanding)
+			}
+		}
+		return out
+	}
+	return v
+}
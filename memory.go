@@ -0,0 +1,211 @@
+package llm_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/HiroCloud/llm-client/tokenizer"
+)
+
+// ErrContextWindowExceeded is returned when messages cannot be trimmed enough to fit the budget.
+var ErrContextWindowExceeded = errors.New("messages exceed the context window")
+
+// TokenCounter returns the number of prompt tokens a message will use, including framing overhead.
+type TokenCounter func(m Message) int
+
+// EstimateTokens is a provider independent TokenCounter using the tokenizer.Estimator
+// (~4 characters per token) plus the per message framing.
+func EstimateTokens(m Message) int {
+	return countMessage(tokenizer.Estimator{}, m)
+}
+
+// Summarizer condenses older messages into a short text that replaces them in the history.
+type Summarizer func(ctx context.Context, messages []Message) (string, error)
+
+// ClientSummarizer returns a Summarizer asking client to summarize the messages.
+func ClientSummarizer(client AIClient, model string, maxTokens int) Summarizer {
+	return func(ctx context.Context, messages []Message) (string, error) {
+		var transcript strings.Builder
+		for _, m := range messages {
+			switch {
+			case m.FunctionCall != nil:
+				fmt.Fprintf(&transcript, "%s called %s(%s)\n", m.Role, m.FunctionCall.Name, m.FunctionCall.Arguments)
+			case m.Role == RoleFunction:
+				fmt.Fprintf(&transcript, "%s returned: %s\n", m.Name, m.Content)
+			default:
+				fmt.Fprintf(&transcript, "%s: %s\n", m.Role, m.Content)
+			}
+		}
+		resp, err := client.ChatCompletion(ctx, ChatRequest{
+			Model: model,
+			Messages: []Message{
+				{Role: RoleSystem, Content: "Summarize the following conversation. Keep facts, decisions, tool results and open questions, drop small talk."},
+				{Role: RoleUser, Content: transcript.String()},
+			},
+			Options: GenOptions{MaxTokens: maxTokens},
+		})
+		if err != nil {
+			return "", err
+		}
+		if len(resp.Choices) == 0 {
+			return "", errors.New("no summary returned")
+		}
+		return resp.Choices[0].Content, nil
+	}
+}
+
+// memorySummaryName marks the summary message inserted by Memory so a later Fit can fold it
+// into a new summary instead of pinning it like a regular system message.
+const memorySummaryName = "conversation_summary"
+
+// Memory keeps a conversation under a token budget. Leading system messages, the latest
+// user message and the most recent turn are always kept; older turns are dropped (or
+// summarized when a Summarizer is set). An assistant function call and the function
+// results that follow it are treated as one unit so they are never split.
+type Memory struct {
+	MaxTokens     int          // Context window of the model
+	ReserveTokens int          // Tokens kept free for the model's answer
+	SummaryTokens int          // Tokens reserved for the summary message (defaults to 512)
+	Counter       TokenCounter // Defaults to EstimateTokens
+	Summarizer    Summarizer   // Optional, older turns are dropped when nil
+}
+
+// NewModelMemory creates a Memory sized for model's context window, reserving reserve tokens
+// for the answer and counting with the model's tokenizer. It returns an error for unknown models.
+func NewModelMemory(model string, reserve int) (*Memory, error) {
+	window := ContextWindow(model)
+	if window == 0 {
+		return nil, fmt.Errorf("unknown context window for model %s", model)
+	}
+	return &Memory{MaxTokens: window, ReserveTokens: reserve, Counter: MessageTokenCounter(model)}, nil
+}
+
+// Budget returns the number of tokens available to the messages.
+func (m *Memory) Budget() int {
+	return m.MaxTokens - m.ReserveTokens
+}
+
+// Count returns the token count of every message.
+func (m *Memory) Count(messages []Message) []int {
+	counter := m.Counter
+	if counter == nil {
+		counter = EstimateTokens
+	}
+	counts := make([]int, len(messages))
+	for i, msg := range messages {
+		counts[i] = counter(msg)
+	}
+	return counts
+}
+
+// Tokens returns the total token count of messages.
+func (m *Memory) Tokens(messages []Message) int {
+	total := 0
+	for _, c := range m.Count(messages) {
+		total += c
+	}
+	return total
+}
+
+// memoryUnit is a run of messages that is kept or removed as a whole.
+type memoryUnit struct {
+	start, end int // messages[start:end]
+	tokens     int
+	keep       bool
+}
+
+// Fit returns messages trimmed to the budget. The input slice is not modified.
+func (m *Memory) Fit(ctx context.Context, messages []Message) ([]Message, error) {
+	budget := m.Budget()
+	counts := m.Count(messages)
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	if m.MaxTokens <= 0 || total <= budget {
+		return messages, nil
+	}
+
+	// leading system messages are pinned
+	pinned := 0
+	for pinned < len(messages) && messages[pinned].Role == RoleSystem && messages[pinned].Name != memorySummaryName {
+		pinned++
+	}
+	units := memoryUnits(messages, counts, pinned)
+	if len(units) > 0 {
+		units[len(units)-1].keep = true
+	}
+	for i := len(units) - 1; i >= 0; i-- {
+		if messages[units[i].start].Role == RoleUser {
+			units[i].keep = true
+			break
+		}
+	}
+
+	reserve := 0
+	if m.Summarizer != nil {
+		reserve = m.SummaryTokens
+		if reserve <= 0 {
+			reserve = 512
+		}
+	}
+	// drop the oldest units first
+	dropped := make([]bool, len(units))
+	for i := range units {
+		if total+reserve <= budget {
+			break
+		}
+		if units[i].keep {
+			continue
+		}
+		dropped[i] = true
+		total -= units[i].tokens
+	}
+	if total+reserve > budget {
+		return nil, fmt.Errorf("%w: %d tokens left after trimming, budget %d", ErrContextWindowExceeded, total+reserve, budget)
+	}
+
+	out := append([]Message(nil), messages[:pinned]...)
+	var removed []Message
+	for i, u := range units {
+		if dropped[i] {
+			removed = append(removed, messages[u.start:u.end]...)
+		}
+	}
+	if m.Summarizer != nil && len(removed) > 0 {
+		summary, err := m.Summarizer(ctx, removed)
+		if err != nil {
+			return nil, fmt.Errorf("summarize conversation: %w", err)
+		}
+		out = append(out, Message{
+			Role:    RoleSystem,
+			Name:    memorySummaryName,
+			Content: "Summary of the earlier conversation:\n" + summary,
+		})
+	}
+	for i, u := range units {
+		if !dropped[i] {
+			out = append(out, messages[u.start:u.end]...)
+		}
+	}
+	return out, nil
+}
+
+// memoryUnits groups messages[from:] so that a function call and its results stay together.
+func memoryUnits(messages []Message, counts []int, from int) []memoryUnit {
+	var units []memoryUnit
+	for i := from; i < len(messages); {
+		u := memoryUnit{start: i, end: i + 1, tokens: counts[i]}
+		if messages[i].FunctionCall != nil {
+			for u.end < len(messages) && messages[u.end].Role == RoleFunction {
+				u.tokens += counts[u.end]
+				u.end++
+			}
+		}
+		units = append(units, u)
+		i = u.end
+	}
+	return units
+}
@@ -0,0 +1,159 @@
+package llm_client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/HiroCloud/llm-client/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wordCounter counts one token per word so budgets in tests are easy to follow.
+func wordCounter(m Message) int {
+	n := len(strings.Fields(m.Content))
+	if m.FunctionCall != nil {
+		n++
+	}
+	return n
+}
+
+func toolTurn(id, result string) []Message {
+	return []Message{
+		{Role: RoleAssistant, FunctionCall: &FunctionCall{ID: id, Name: "lookup", Arguments: "{}"}},
+		{Role: RoleFunction, Name: "lookup", Content: result},
+	}
+}
+
+func TestMemoryFitDropsOldTurns(t *testing.T) {
+	var messages []Message
+	messages = append(messages, Message{Role: RoleSystem, Content: "be brief"})
+	messages = append(messages, Message{Role: RoleUser, Content: "first question here"})
+	messages = append(messages, Message{Role: RoleAssistant, Content: "first answer"})
+	messages = append(messages, Message{Role: RoleUser, Content: "second"})
+	messages = append(messages, toolTurn("1", "one two three four")...)
+	messages = append(messages, toolTurn("2", "five six")...)
+
+	m := &Memory{MaxTokens: 12, ReserveTokens: 2, Counter: wordCounter}
+	assert.Equal(t, 16, m.Tokens(messages))
+
+	out, err := m.Fit(context.Background(), messages)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, m.Tokens(out), m.Budget())
+	require.Len(t, out, 4)
+	assert.Equal(t, "be brief", out[0].Content)
+	assert.Equal(t, "second", out[1].Content)
+	// the last call and its result are kept together
+	assert.Equal(t, "2", out[2].FunctionCall.ID)
+	assert.Equal(t, "five six", out[3].Content)
+	assert.Len(t, messages, 8)
+
+	m.MaxTokens = 5
+	_, err = m.Fit(context.Background(), messages)
+	assert.ErrorIs(t, err, ErrContextWindowExceeded)
+}
+
+func TestMemoryFitSummarizes(t *testing.T) {
+	var summarized []Message
+	m := &Memory{
+		MaxTokens:     10,
+		SummaryTokens: 4,
+		Counter:       wordCounter,
+		Summarizer: func(ctx context.Context, messages []Message) (string, error) {
+			summarized = messages
+			return "user said hi", nil
+		},
+	}
+	messages := []Message{
+		{Role: RoleUser, Content: "hi there how are you"},
+		{Role: RoleAssistant, Content: "fine thanks for asking"},
+		{Role: RoleUser, Content: "tell a joke"},
+	}
+	out, err := m.Fit(context.Background(), messages)
+	require.NoError(t, err)
+	require.Len(t, out, 2)
+	assert.Equal(t, memorySummaryName, out[0].Name)
+	assert.Contains(t, out[0].Content, "user said hi")
+	assert.Equal(t, "tell a joke", out[1].Content)
+	assert.Len(t, summarized, 2)
+
+	m.Summarizer = func(ctx context.Context, messages []Message) (string, error) {
+		return "", errors.New("offline")
+	}
+	_, err = m.Fit(context.Background(), messages)
+	assert.Error(t, err)
+}
+
+func TestContextWindow(t *testing.T) {
+	assert.Equal(t, 128000, ContextWindow("gpt-4o-mini"))
+	assert.Equal(t, 8192, ContextWindow("gpt-4-0613"))
+	assert.Equal(t, 0, ContextWindow("unknown-model"))
+
+	m, err := NewModelMemory("gemini-2.5-flash", 1000)
+	require.NoError(t, err)
+	assert.Equal(t, 1048576-1000, m.Budget())
+	_, err = NewModelMemory("unknown-model", 0)
+	assert.Error(t, err)
+}
+
+// lookupDocument returns a long document
+func lookupDocument() string {
+	return strings.Repeat("word ", 20)
+}
+
+func TestResolveChatWithMemory(t *testing.T) {
+	lookup, err := tools.CreateDef(lookupDocument)
+	require.NoError(t, err)
+	name := lookup.Function.Name
+
+	client := &fakeClient{responses: []Response{
+		{FunctionCalls: []*FunctionCall{{ID: "1", Name: name, Arguments: "{}"}}},
+		{FunctionCalls: []*FunctionCall{{ID: "2", Name: name, Arguments: "{}"}}},
+		{Content: "done"},
+	}}
+	answer, err := ResolveChatWithOptions(context.Background(), client, []Message{
+		{Role: RoleSystem, Content: "system prompt"},
+		{Role: RoleUser, Content: "read it twice"},
+	}, []llm_models.Tool{*lookup}, ToolLoopOptions{
+		MaxCalls: 5,
+		Memory:   &Memory{MaxTokens: 30, Counter: wordCounter},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "done", answer)
+	require.Len(t, client.calls, 3)
+	// the first lookup result was dropped before the last request
+	last := client.calls[2]
+	require.Len(t, last, 4)
+	assert.Equal(t, "system prompt", last[0].Content)
+	assert.Equal(t, "read it twice", last[1].Content)
+	assert.Equal(t, "2", last[2].FunctionCall.ID)
+}
+
+func TestRunToolsMemoryKeepsTranscript(t *testing.T) {
+	lookup, err := tools.CreateDef(lookupDocument)
+	require.NoError(t, err)
+	name := lookup.Function.Name
+
+	client := &fakeClient{responses: []Response{
+		{FunctionCalls: []*FunctionCall{{ID: "1", Name: name, Arguments: "{}"}}},
+		{FunctionCalls: []*FunctionCall{{ID: "2", Name: name, Arguments: "{}"}}},
+		{Content: "done"},
+	}}
+	res, err := RunTools(context.Background(), client, []Message{
+		{Role: RoleSystem, Content: "system prompt"},
+		{Role: RoleUser, Content: "read it twice"},
+	}, []llm_models.Tool{*lookup}, ToolLoopOptions{
+		MaxCalls: 5,
+		Memory:   &Memory{MaxTokens: 30, Counter: wordCounter},
+	})
+	require.NoError(t, err)
+	require.Len(t, client.calls[2], 4)
+	// the request was trimmed, the transcript is not
+	require.Len(t, res.Messages, 7)
+	assert.Equal(t, "1", res.Messages[2].FunctionCall.ID)
+	assert.Equal(t, "2", res.Messages[4].FunctionCall.ID)
+	assert.Equal(t, "done", res.Messages[6].Content)
+}
@@ -0,0 +1,209 @@
+package llm_client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+)
+
+// ErrUnsupportedRequest is returned when a request asks a model for something it cannot do.
+var ErrUnsupportedRequest = errors.New("request not supported by model")
+
+// ModelInfo describes what a model can do. Name matches every model starting with it,
+// so "gpt-4o" also describes "gpt-4o-mini" unless a longer entry exists.
+type ModelInfo struct {
+	Name            string                 `json:"name"`
+	ContextWindow   int                    `json:"context_window,omitempty"`    // input + output tokens, 0 if unknown
+	MaxOutputTokens int                    `json:"max_output_tokens,omitempty"` // 0 if unknown
+	Types           []llm_models.ModelType `json:"types"`                       // what the model produces or accepts
+	Tools           bool                   `json:"tools"`                       // supports function/tool calling
+	JSONSchema      bool                   `json:"json_schema"`                 // supports structured output with a JSON schema
+}
+
+// Supports reports whether the model has the given type.
+func (m ModelInfo) Supports(t llm_models.ModelType) bool {
+	return slices.Contains(m.Types, t)
+}
+
+var (
+	chatTypes       = []llm_models.ModelType{llm_models.ModelTypeChat}
+	multiModalTypes = []llm_models.ModelType{llm_models.ModelTypeChat, llm_models.ModelTypeMultiModal}
+	imageTypes      = []llm_models.ModelType{llm_models.ModelTypeImage}
+)
+
+// knownModels is the built-in model list used by DefaultModels.
+var knownModels = []ModelInfo{
+	// OpenAI
+	{Name: "gpt-3.5-turbo", ContextWindow: 16385, MaxOutputTokens: 4096, Types: chatTypes, Tools: true},
+	{Name: "gpt-4", ContextWindow: 8192, MaxOutputTokens: 8192, Types: chatTypes, Tools: true},
+	{Name: "gpt-4-32k", ContextWindow: 32768, MaxOutputTokens: 8192, Types: chatTypes, Tools: true},
+	{Name: "gpt-4-turbo", ContextWindow: 128000, MaxOutputTokens: 4096, Types: multiModalTypes, Tools: true},
+	{Name: "gpt-4-1106", ContextWindow: 128000, MaxOutputTokens: 4096, Types: chatTypes, Tools: true},
+	{Name: "gpt-4-0125", ContextWindow: 128000, MaxOutputTokens: 4096, Types: chatTypes, Tools: true},
+	{Name: "gpt-4-vision", ContextWindow: 128000, MaxOutputTokens: 4096, Types: multiModalTypes},
+	{Name: "gpt-4.5", ContextWindow: 128000, MaxOutputTokens: 16384, Types: multiModalTypes, Tools: true, JSONSchema: true},
+	{Name: "gpt-4o", ContextWindow: 128000, MaxOutputTokens: 16384, Types: multiModalTypes, Tools: true, JSONSchema: true},
+	{Name: "gpt-4o-audio", ContextWindow: 128000, MaxOutputTokens: 16384, Types: []llm_models.ModelType{llm_models.ModelTypeChat, llm_models.ModelTypeAudio}, Tools: true},
+	{Name: "gpt-4.1", ContextWindow: 1047576, MaxOutputTokens: 32768, Types: multiModalTypes, Tools: true, JSONSchema: true},
+	{Name: "gpt-5", ContextWindow: 400000, MaxOutputTokens: 128000, Types: multiModalTypes, Tools: true, JSONSchema: true},
+	{Name: "o1", ContextWindow: 200000, MaxOutputTokens: 100000, Types: multiModalTypes, Tools: true, JSONSchema: true},
+	{Name: "o3", ContextWindow: 200000, MaxOutputTokens: 100000, Types: multiModalTypes, Tools: true, JSONSchema: true},
+	{Name: "o4-mini", ContextWindow: 200000, MaxOutputTokens: 100000, Types: multiModalTypes, Tools: true, JSONSchema: true},
+	{Name: "dall-e-2", Types: imageTypes},
+	{Name: "dall-e-3", Types: imageTypes},
+	{Name: "gpt-image-1", Types: []llm_models.ModelType{llm_models.ModelTypeImage, llm_models.ModelTypeMultiModal}},
+	// Google
+	{Name: "gemini-1.5-flash", ContextWindow: 1048576, MaxOutputTokens: 8192, Types: multiModalTypes, Tools: true, JSONSchema: true},
+	{Name: "gemini-1.5-pro", ContextWindow: 2097152, MaxOutputTokens: 8192, Types: multiModalTypes, Tools: true, JSONSchema: true},
+	{Name: "gemini-2.0-flash", ContextWindow: 1048576, MaxOutputTokens: 8192, Types: multiModalTypes, Tools: true, JSONSchema: true},
+	{Name: "gemini-2.5", ContextWindow: 1048576, MaxOutputTokens: 65536, Types: multiModalTypes, Tools: true, JSONSchema: true},
+	{Name: "gemini-2.5-flash-image", ContextWindow: 32768, MaxOutputTokens: 32768, Types: []llm_models.ModelType{llm_models.ModelTypeChat, llm_models.ModelTypeImage, llm_models.ModelTypeMultiModal}},
+	{Name: "gemini-3", ContextWindow: 1048576, MaxOutputTokens: 65536, Types: multiModalTypes, Tools: true, JSONSchema: true},
+	{Name: "imagen-3", Types: imageTypes},
+	{Name: "imagen-4", Types: imageTypes},
+}
+
+// DefaultModels is the registry clients validate against unless configured otherwise.
+var DefaultModels = NewModelRegistry(knownModels...)
+
+// ModelRegistry looks up model capabilities by name prefix, longer prefixes take precedence.
+// Requests for models that are not registered are never rejected.
+type ModelRegistry struct {
+	mu     sync.RWMutex
+	models map[string]ModelInfo
+}
+
+// NewModelRegistry creates a registry holding models.
+func NewModelRegistry(models ...ModelInfo) *ModelRegistry {
+	r := &ModelRegistry{models: map[string]ModelInfo{}}
+	for _, m := range models {
+		r.Register(m)
+	}
+	return r
+}
+
+// Register adds or replaces a model.
+func (r *ModelRegistry) Register(m ModelInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[m.Name] = m
+}
+
+// LoadJSON registers the models of a JSON array of ModelInfo, e.g. from a config file,
+// replacing built-in entries with the same name.
+func (r *ModelRegistry) LoadJSON(data []byte) error {
+	var models []ModelInfo
+	if err := json.Unmarshal(data, &models); err != nil {
+		return fmt.Errorf("parse model registry: %w", err)
+	}
+	for _, m := range models {
+		if m.Name == "" {
+			return errors.New("parse model registry: model without name")
+		}
+		r.Register(m)
+	}
+	return nil
+}
+
+// Lookup returns the entry describing model.
+func (r *ModelRegistry) Lookup(model string) (ModelInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	best, found := ModelInfo{}, false
+	for prefix, m := range r.models {
+		if strings.HasPrefix(model, prefix) && (!found || len(prefix) > len(best.Name)) {
+			best, found = m, true
+		}
+	}
+	return best, found
+}
+
+// ValidateChat checks a chat request against the model's capabilities.
+func (r *ModelRegistry) ValidateChat(req ChatRequest) error {
+	if r == nil {
+		return nil
+	}
+	info, ok := r.Lookup(req.Model)
+	if !ok {
+		return nil
+	}
+	if !info.Supports(llm_models.ModelTypeChat) {
+		return fmt.Errorf("%w: %s is not a chat model", ErrUnsupportedRequest, req.Model)
+	}
+	if len(req.Functions) > 0 && !info.Tools {
+		return fmt.Errorf("%w: %s does not support tool calling", ErrUnsupportedRequest, req.Model)
+	}
+	if err := info.checkMaxTokens(req.Model, req.Options.MaxTokens); err != nil {
+		return err
+	}
+	if info.ContextWindow > 0 {
+		// tokenizing is expensive for long prompts, it is only needed when the upper bound
+		// does not fit
+		prompt, err := countRequest(byteCounter{}, req)
+		if err != nil {
+			return err
+		}
+		if prompt+req.Options.MaxTokens > info.ContextWindow {
+			prompt, err = CountRequestTokens(req)
+			if err != nil {
+				return err
+			}
+		}
+		if prompt+req.Options.MaxTokens > info.ContextWindow {
+			return fmt.Errorf("%w: %s has a context window of %d tokens, the request needs about %d",
+				ErrContextWindowExceeded, req.Model, info.ContextWindow, prompt+req.Options.MaxTokens)
+		}
+	}
+	return nil
+}
+
+// ValidateText checks a text completion request against the model's capabilities.
+func (r *ModelRegistry) ValidateText(req TextRequest) error {
+	if r == nil {
+		return nil
+	}
+	info, ok := r.Lookup(req.Model)
+	if !ok {
+		return nil
+	}
+	return info.checkMaxTokens(req.Model, req.Options.MaxTokens)
+}
+
+// ValidateImage checks that the model can generate images.
+func (r *ModelRegistry) ValidateImage(req ImageRequest) error {
+	if r == nil {
+		return nil
+	}
+	if info, ok := r.Lookup(req.Model); ok && !info.Supports(llm_models.ModelTypeImage) {
+		return fmt.Errorf("%w: %s cannot generate images", ErrUnsupportedRequest, req.Model)
+	}
+	return nil
+}
+
+// supports reports whether model is registered with the given type.
+func (r *ModelRegistry) supports(model string, t llm_models.ModelType) bool {
+	if r == nil {
+		return false
+	}
+	info, ok := r.Lookup(model)
+	return ok && info.Supports(t)
+}
+
+func (m ModelInfo) checkMaxTokens(model string, maxTokens int) error {
+	if m.MaxOutputTokens > 0 && maxTokens > m.MaxOutputTokens {
+		return fmt.Errorf("%w: %s generates at most %d tokens, %d requested",
+			ErrUnsupportedRequest, model, m.MaxOutputTokens, maxTokens)
+	}
+	return nil
+}
+
+// ContextWindow returns the context window of model in tokens, or 0 if the model is unknown.
+func ContextWindow(model string) int {
+	info, _ := DefaultModels.Lookup(model)
+	return info.ContextWindow
+}
@@ -0,0 +1,131 @@
+package llm_client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelRegistryLookup(t *testing.T) {
+	info, ok := DefaultModels.Lookup("gpt-4o-mini")
+	require.True(t, ok)
+	assert.Equal(t, "gpt-4o", info.Name)
+	assert.True(t, info.Supports(llm_models.ModelTypeMultiModal))
+
+	info, ok = DefaultModels.Lookup("gemini-2.5-flash-image-preview")
+	require.True(t, ok)
+	assert.True(t, info.Supports(llm_models.ModelTypeImage))
+	assert.False(t, info.Tools)
+
+	_, ok = DefaultModels.Lookup("my-local-model")
+	assert.False(t, ok)
+}
+
+func TestModelRegistryLookupGPT4(t *testing.T) {
+	type TestCase struct {
+		model  string
+		window int
+	}
+	for _, tc := range []TestCase{
+		{model: "gpt-4", window: 8192},
+		{model: "gpt-4-0613", window: 8192},
+		{model: "gpt-4-32k-0613", window: 32768},
+		{model: "gpt-4-turbo-2024-04-09", window: 128000},
+		{model: "gpt-4-1106-preview", window: 128000},
+		{model: "gpt-4-0125-preview", window: 128000},
+		{model: "gpt-4-vision-preview", window: 128000},
+		{model: "gpt-4.5-preview", window: 128000},
+		{model: "gpt-4.1-mini", window: 1047576},
+		{model: "gpt-4o-mini", window: 128000},
+	} {
+		t.Run(tc.model, func(t *testing.T) {
+			info, ok := DefaultModels.Lookup(tc.model)
+			require.True(t, ok)
+			assert.Equal(t, tc.window, info.ContextWindow)
+		})
+	}
+}
+
+func TestModelRegistryValidate(t *testing.T) {
+	r := NewModelRegistry(knownModels...)
+	hello := []Message{{Role: RoleUser, Content: "hello"}}
+
+	assert.NoError(t, r.ValidateChat(ChatRequest{Model: "gpt-4o", Messages: hello}))
+	assert.NoError(t, r.ValidateChat(ChatRequest{Model: "unknown", Options: GenOptions{MaxTokens: 1 << 30}}))
+
+	err := r.ValidateChat(ChatRequest{Model: "dall-e-3", Messages: hello})
+	assert.ErrorIs(t, err, ErrUnsupportedRequest)
+
+	err = r.ValidateChat(ChatRequest{Model: "gemini-2.5-flash-image", Messages: hello, Functions: []FunctionDef{{Name: "f"}}})
+	assert.ErrorIs(t, err, ErrUnsupportedRequest)
+	assert.Contains(t, err.Error(), "tool calling")
+
+	err = r.ValidateChat(ChatRequest{Model: "gpt-4o", Messages: hello, Options: GenOptions{MaxTokens: 20000}})
+	assert.ErrorIs(t, err, ErrUnsupportedRequest)
+
+	long := []Message{{Role: RoleUser, Content: strings.Repeat("hello ", 9000)}}
+	err = r.ValidateChat(ChatRequest{Model: "gpt-4-0613", Messages: long})
+	assert.ErrorIs(t, err, ErrContextWindowExceeded)
+	// longer than the window in bytes, the exact count decides
+	fits := []Message{{Role: RoleUser, Content: strings.Repeat("hello ", 2000)}}
+	assert.NoError(t, r.ValidateChat(ChatRequest{Model: "gpt-4-0613", Messages: fits}))
+
+	assert.NoError(t, r.ValidateImage(ImageRequest{Model: "imagen-4.0-generate-001"}))
+	assert.ErrorIs(t, r.ValidateImage(ImageRequest{Model: "gpt-4o"}), ErrUnsupportedRequest)
+	assert.ErrorIs(t, r.ValidateText(TextRequest{Model: "gpt-4", Options: GenOptions{MaxTokens: 9000}}), ErrUnsupportedRequest)
+
+	// config overrides the built-in entry
+	require.NoError(t, r.LoadJSON([]byte(`[{"name":"gpt-4o","context_window":128000,"max_output_tokens":32768,"types":["chat"],"tools":true}]`)))
+	assert.NoError(t, r.ValidateChat(ChatRequest{Model: "gpt-4o", Messages: hello, Options: GenOptions{MaxTokens: 20000}}))
+	assert.Error(t, r.LoadJSON([]byte(`[{"context_window":1}]`)))
+
+	var nilRegistry *ModelRegistry
+	assert.NoError(t, nilRegistry.ValidateChat(ChatRequest{Model: "dall-e-3"}))
+}
+
+func TestClientValidatesRequests(t *testing.T) {
+	var imageModel string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/images/generations", func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ImageRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		imageModel = req.Model
+		_ = json.NewEncoder(w).Encode(openai.ImageResponse{})
+	})
+	mux.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: RoleAssistant, Content: "ok"}}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	config := openai.DefaultConfig("test")
+	config.BaseURL = server.URL + "/v1"
+	client := NewOpenAIClientFromConfig(config, "gpt-4o")
+	ctx := context.Background()
+
+	// the chat default model is not used for images
+	_, err := client.GenerateImage(ctx, ImageRequest{Prompt: "a cat", N: 1})
+	require.NoError(t, err)
+	assert.Equal(t, openai.CreateImageModelDallE3, imageModel)
+
+	_, err = client.GenerateImage(ctx, ImageRequest{Model: "gpt-4o-mini", Prompt: "a cat"})
+	assert.ErrorIs(t, err, ErrUnsupportedRequest)
+
+	req := ChatRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}, Options: GenOptions{MaxTokens: 100000}}
+	_, err = client.ChatCompletion(ctx, req)
+	assert.ErrorIs(t, err, ErrUnsupportedRequest)
+
+	client.SetModelRegistry(nil)
+	resp, err := client.ChatCompletion(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.Choices[0].Content)
+}
@@ -14,23 +14,77 @@ import (
 type OpenAIClient struct {
 	client       *openai.Client // underlying OpenAI SDK client
 	defaultModel string         // default model to use if none specified in request
+	models       *ModelRegistry // requests are validated against it, nil disables validation
+}
+
+// NewOpenAIClient creates an OpenAIClient authenticated with apiKey.
+// defaultModel is used whenever a request does not name a model.
+func NewOpenAIClient(apiKey string, defaultModel string) *OpenAIClient {
+	return NewOpenAIClientFromConfig(openai.DefaultConfig(apiKey), defaultModel)
+}
+
+// NewOpenAIClientFromConfig creates an OpenAIClient from a full SDK config, e.g. to point
+// it at a different BaseURL (Azure, a proxy or a stand-in server in tests).
+func NewOpenAIClientFromConfig(config openai.ClientConfig, defaultModel string) *OpenAIClient {
+	return &OpenAIClient{
+		client:       openai.NewClientWithConfig(config),
+		defaultModel: defaultModel,
+		models:       DefaultModels,
+	}
+}
+
+// SetModelRegistry replaces the registry requests are validated against, nil disables validation.
+func (c *OpenAIClient) SetModelRegistry(models *ModelRegistry) {
+	c.models = models
 }
 
 // --- Chat Completion (OpenAI) ---
 func (c *OpenAIClient) ChatCompletion(ctx context.Context, req ChatRequest) (ChatResponse, error) {
-	model := req.Model
-	if model == "" {
-		model = c.defaultModel
+	openReq, err := c.openAIChatRequest(req)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+
+	// Call the API
+	resp, err := c.client.CreateChatCompletion(ctx, openReq)
+	if err != nil {
+		return ChatResponse{}, err
 	}
+	return openAIChatResponse(resp), nil
+}
+
+// openAIChatRequest converts our ChatRequest into the OpenAI SDK request.
+func (c *OpenAIClient) openAIChatRequest(req ChatRequest) (openai.ChatCompletionRequest, error) {
+	if req.Model == "" {
+		req.Model = c.defaultModel
+	}
+	if err := c.models.ValidateChat(req); err != nil {
+		return openai.ChatCompletionRequest{}, err
+	}
+	model := req.Model
 
 	// 1) Map our Message → openai.ChatCompletionMessage
-	msgs := make([]openai.ChatCompletionMessage, len(req.Messages))
-	for i, m := range req.Messages {
-		msgs[i] = openai.ChatCompletionMessage{
+	msgs := make([]openai.ChatCompletionMessage, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		msg := openai.ChatCompletionMessage{
 			Role:    m.Role,
 			Content: m.Content,
 			Name:    m.Name,
 		}
+		if len(m.MultiContent) == 0 {
+			msgs = append(msgs, msg)
+			continue
+		}
+		if m.Role == RoleFunction || m.Role == openai.ChatMessageRoleTool {
+			// OpenAI only accepts images in user messages, the tool result stays text and the
+			// parts follow in a user message
+			parts := Message{Content: fmt.Sprintf("Content returned by %s:", m.Name), MultiContent: m.MultiContent}
+			msgs = append(msgs, msg, openai.ChatCompletionMessage{Role: RoleUser, MultiContent: openAIMessageParts(parts)})
+			continue
+		}
+		msg.Content = ""
+		msg.MultiContent = openAIMessageParts(m)
+		msgs = append(msgs, msg)
 	}
 
 	// 2) Build the new ChatCompletionRequest
@@ -49,7 +103,7 @@ func (c *OpenAIClient) ChatCompletion(ctx context.Context, req ChatRequest) (Cha
 			// marshal your JSON schema / parameters
 			paramsJSON, err := json.Marshal(fn.Parameters)
 			if err != nil {
-				return ChatResponse{}, err
+				return openai.ChatCompletionRequest{}, err
 			}
 			tools[i] = openai.Tool{
 				Type: openai.ToolTypeFunction,
@@ -72,14 +126,27 @@ func (c *OpenAIClient) ChatCompletion(ctx context.Context, req ChatRequest) (Cha
 		//			ToolName: "",                                         // leave blank for auto/none
 		//		}
 	}
+	return openReq, nil
+}
 
-	// 5) Call the API
-	resp, err := c.client.CreateChatCompletion(ctx, openReq)
-	if err != nil {
-		return ChatResponse{}, err
+// openAIMessageParts converts the content and the parts of m to OpenAI message parts.
+func openAIMessageParts(m Message) []openai.ChatMessagePart {
+	var parts []openai.ChatMessagePart
+	if m.Content != "" {
+		parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: m.Content})
+	}
+	for _, p := range m.MultiContent {
+		part := openai.ChatMessagePart{Type: openai.ChatMessagePartType(p.Type), Text: p.Text}
+		if p.ImageURL != nil {
+			part.ImageURL = &openai.ChatMessageImageURL{URL: p.ImageURL.URL, Detail: openai.ImageURLDetail(p.ImageURL.Detail)}
+		}
+		parts = append(parts, part)
 	}
+	return parts
+}
 
-	// 6) Convert back to our ChatResponse
+// openAIChatResponse converts an OpenAI chat completion back to our ChatResponse.
+func openAIChatResponse(resp openai.ChatCompletionResponse) ChatResponse {
 	out := ChatResponse{
 		Usage: TokenUsage{
 			PromptTokens:     resp.Usage.PromptTokens,
@@ -107,14 +174,16 @@ func (c *OpenAIClient) ChatCompletion(ctx context.Context, req ChatRequest) (Cha
 		}
 		out.Choices = append(out.Choices, choice)
 	}
-	return out, nil
+	return out
 }
 
 // ChatCompletionStream for OpenAI returns a stream of incremental chat chunks.
 func (c *OpenAIClient) ChatCompletionStream(ctx context.Context, req ChatRequest) (ChatStream, error) {
-	// (Conversion of req to openReq is same as above)
-	// ... prepare openReq as in ChatCompletion ...
-	openReq := openai.ChatCompletionRequest{ /* ... fill as above ... */ }
+	openReq, err := c.openAIChatRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	openReq.Stream = true
 	stream, err := c.client.CreateChatCompletionStream(ctx, openReq)
 	if err != nil {
 		return nil, err
@@ -162,12 +231,14 @@ func (s *openAIChatStream) Close() error {
 
 // --- Text Completion (OpenAI) ---
 func (c *OpenAIClient) TextCompletion(ctx context.Context, req TextRequest) (TextResponse, error) {
-	model := req.Model
-	if model == "" {
-		model = c.defaultModel
+	if req.Model == "" {
+		req.Model = c.defaultModel
+	}
+	if err := c.models.ValidateText(req); err != nil {
+		return TextResponse{}, err
 	}
 	openReq := openai.CompletionRequest{
-		Model:       model,
+		Model:       req.Model,
 		Prompt:      req.Prompt,
 		Temperature: float32(req.Options.Temperature),
 		TopP:        float32(req.Options.TopP),
@@ -193,6 +264,12 @@ func (c *OpenAIClient) TextCompletion(ctx context.Context, req TextRequest) (Tex
 }
 
 func (c *OpenAIClient) TextCompletionStream(ctx context.Context, req TextRequest) (TextStream, error) {
+	if req.Model == "" {
+		req.Model = c.defaultModel
+	}
+	if err := c.models.ValidateText(req); err != nil {
+		return nil, err
+	}
 	openReq := openai.CompletionRequest{
 		Model:       req.Model,
 		Prompt:      req.Prompt,
@@ -216,10 +293,15 @@ func (c *OpenAIClient) GenerateResponse(
 	// 1) Convert Tool → FunctionDef for the API
 	funcDefs := make([]FunctionDef, len(tools))
 	for i, t := range tools {
+		params, err := t.Function.JSONSchema()
+		if err != nil {
+			return Response{}, err
+		}
 		funcDefs[i] = FunctionDef{
 			Name:        t.Function.Name,
 			Description: t.Function.Description,
-			Parameters:  t.Function.Parameters,
+			// OpenAI resolves $defs and recursive "#" references itself
+			Parameters: params,
 		}
 	}
 
@@ -273,11 +355,17 @@ func (s *openAITextStream) Close() error {
 
 // --- Image Generation (OpenAI) ---
 func (c *OpenAIClient) GenerateImage(ctx context.Context, req ImageRequest) (ImageResponse, error) {
-	model := req.Model
-	if model == "" {
-		model = "image-alpha-001" // e.g., DALL-E model (if not specified)
+	if req.Model == "" {
+		req.Model = c.defaultModel
+		if !c.models.supports(req.Model, llm_models.ModelTypeImage) {
+			req.Model = openai.CreateImageModelDallE3
+		}
+	}
+	if err := c.models.ValidateImage(req); err != nil {
+		return ImageResponse{}, err
 	}
 	openReq := openai.ImageRequest{
+		Model:          req.Model,
 		Prompt:         req.Prompt,
 		N:              req.N,
 		Size:           "",         // could set e.g. "1024x1024" if needed
@@ -310,3 +398,47 @@ func (c *OpenAIClient) GenerateImage(ctx context.Context, req ImageRequest) (Ima
 	}
 	return out, nil
 }
+
+// --- Embeddings (OpenAI) ---
+
+// openAIEmbeddingBatchSize is the maximum number of inputs accepted by a single embeddings call.
+const openAIEmbeddingBatchSize = 2048
+
+func (c *OpenAIClient) Embed(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = DefaultOpenAIEmbeddingModel
+	}
+	return embedBatches(ctx, req, openAIEmbeddingBatchSize, func(ctx context.Context, input []string) (EmbeddingResponse, error) {
+		resp, err := c.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+			Input:      input,
+			Model:      openai.EmbeddingModel(model),
+			Dimensions: req.Dimensions,
+		})
+		if err != nil {
+			return EmbeddingResponse{}, err
+		}
+		out := EmbeddingResponse{
+			Embeddings: make([][]float32, len(input)),
+			Usage: TokenUsage{
+				PromptTokens: resp.Usage.PromptTokens,
+				TotalTokens:  resp.Usage.TotalTokens,
+			},
+		}
+		for _, e := range resp.Data {
+			if e.Index < 0 || e.Index >= len(input) {
+				return EmbeddingResponse{}, fmt.Errorf("embedding index %d out of range", e.Index)
+			}
+			if out.Embeddings[e.Index] != nil {
+				return EmbeddingResponse{}, fmt.Errorf("duplicate embedding for index %d", e.Index)
+			}
+			out.Embeddings[e.Index] = e.Embedding
+		}
+		for i, e := range out.Embeddings {
+			if e == nil {
+				return EmbeddingResponse{}, fmt.Errorf("no embedding for input %d", i)
+			}
+		}
+		return out, nil
+	})
+}
@@ -0,0 +1,173 @@
+package llm_client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// openAIBatchLine is a single line of an OpenAI batch output or error file.
+type openAIBatchLine struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int                           `json:"status_code"`
+		Body       openai.ChatCompletionResponse `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// openAIBatchFile builds the batch input file for items.
+func (c *OpenAIClient) openAIBatchFile(items []BatchItem) (openai.UploadBatchFileRequest, error) {
+	var file openai.UploadBatchFileRequest
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item.CustomID == "" {
+			return file, fmt.Errorf("batch item without custom id")
+		}
+		if seen[item.CustomID] {
+			return file, fmt.Errorf("duplicate batch custom id %q", item.CustomID)
+		}
+		seen[item.CustomID] = true
+		openReq, err := c.openAIChatRequest(item.Request)
+		if err != nil {
+			return file, fmt.Errorf("batch item %s: %w", item.CustomID, err)
+		}
+		file.AddChatCompletion(item.CustomID, openReq)
+	}
+	return file, nil
+}
+
+// WriteBatchFile writes the OpenAI batch JSONL input file for items to w.
+// Requests without a model use the client's default model.
+func (c *OpenAIClient) WriteBatchFile(w io.Writer, items []BatchItem) error {
+	file, err := c.openAIBatchFile(items)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(file.MarshalJSONL(), '\n'))
+	return err
+}
+
+// SubmitBatch uploads items as a JSONL file and creates a chat completions batch job.
+func (c *OpenAIClient) SubmitBatch(ctx context.Context, items []BatchItem) (BatchJob, error) {
+	file, err := c.openAIBatchFile(items)
+	if err != nil {
+		return BatchJob{}, err
+	}
+	resp, err := c.client.CreateBatchWithUploadFile(ctx, openai.CreateBatchWithUploadFileRequest{
+		Endpoint:               openai.BatchEndpointChatCompletions,
+		UploadBatchFileRequest: file,
+	})
+	if err != nil {
+		return BatchJob{}, err
+	}
+	return openAIBatchJob(resp.Batch), nil
+}
+
+// GetBatch returns the current state of an OpenAI batch job.
+func (c *OpenAIClient) GetBatch(ctx context.Context, id string) (BatchJob, error) {
+	resp, err := c.client.RetrieveBatch(ctx, id)
+	if err != nil {
+		return BatchJob{}, err
+	}
+	return openAIBatchJob(resp.Batch), nil
+}
+
+// CancelBatch cancels an OpenAI batch job.
+func (c *OpenAIClient) CancelBatch(ctx context.Context, id string) error {
+	_, err := c.client.CancelBatch(ctx, id)
+	return err
+}
+
+// BatchResults downloads the output and error files of a finished batch job.
+func (c *OpenAIClient) BatchResults(ctx context.Context, id string) (map[string]BatchItemResult, error) {
+	resp, err := c.client.RetrieveBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]BatchItemResult)
+	for _, fileID := range []*string{resp.OutputFileID, resp.ErrorFileID} {
+		if fileID == nil || *fileID == "" {
+			continue
+		}
+		if err := c.readBatchFile(ctx, *fileID, results); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func (c *OpenAIClient) readBatchFile(ctx context.Context, fileID string, results map[string]BatchItemResult) error {
+	content, err := c.client.GetFileContent(ctx, fileID)
+	if err != nil {
+		return fmt.Errorf("download batch file %s: %w", fileID, err)
+	}
+	defer content.Close()
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return fmt.Errorf("download batch file %s: %w", fileID, err)
+	}
+	return parseOpenAIBatchOutput(data, results)
+}
+
+// parseOpenAIBatchOutput maps the lines of an OpenAI batch output file to results by custom_id.
+func parseOpenAIBatchOutput(data []byte, results map[string]BatchItemResult) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line openAIBatchLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return fmt.Errorf("invalid batch output line: %w", err)
+		}
+		res := BatchItemResult{CustomID: line.CustomID}
+		switch {
+		case line.Error != nil:
+			res.Err = fmt.Errorf("batch request failed: %s: %s", line.Error.Code, line.Error.Message)
+		case line.Response == nil:
+			res.Err = fmt.Errorf("batch request returned no response")
+		case line.Response.StatusCode != 200:
+			res.Err = fmt.Errorf("batch request failed with status %d", line.Response.StatusCode)
+		default:
+			res.Response = openAIChatResponse(line.Response.Body)
+		}
+		results[line.CustomID] = res
+	}
+	return scanner.Err()
+}
+
+func openAIBatchJob(b openai.Batch) BatchJob {
+	job := BatchJob{
+		ID:             b.ID,
+		ProviderStatus: b.Status,
+		Total:          b.RequestCounts.Total,
+		Completed:      b.RequestCounts.Completed,
+		Failed:         b.RequestCounts.Failed,
+	}
+	switch b.Status {
+	case "validating":
+		job.Status = BatchJobStatusPending
+	case "completed":
+		job.Status = BatchJobStatusSucceeded
+	case "failed":
+		job.Status = BatchJobStatusFailed
+	case "cancelled":
+		job.Status = BatchJobStatusCancelled
+	case "expired":
+		job.Status = BatchJobStatusExpired
+	default:
+		// in_progress, finalizing and cancelling
+		job.Status = BatchJobStatusRunning
+	}
+	return job
+}
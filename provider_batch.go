@@ -0,0 +1,83 @@
+package llm_client
+
+import (
+	"context"
+	"time"
+)
+
+// BatchJobStatus is the provider independent state of a provider batch job.
+type BatchJobStatus string
+
+const (
+	BatchJobStatusPending   BatchJobStatus = "pending"
+	BatchJobStatusRunning   BatchJobStatus = "running"
+	BatchJobStatusSucceeded BatchJobStatus = "succeeded"
+	BatchJobStatusFailed    BatchJobStatus = "failed"
+	BatchJobStatusCancelled BatchJobStatus = "cancelled"
+	BatchJobStatusExpired   BatchJobStatus = "expired"
+)
+
+// Done reports whether the job reached a final state and will not change anymore.
+func (s BatchJobStatus) Done() bool {
+	switch s {
+	case BatchJobStatusSucceeded, BatchJobStatusFailed, BatchJobStatusCancelled, BatchJobStatusExpired:
+		return true
+	}
+	return false
+}
+
+// BatchItem is a single request of a provider batch, CustomID is used to match the result.
+type BatchItem struct {
+	CustomID string
+	Request  ChatRequest
+}
+
+// BatchItemResult is the outcome of a single BatchItem.
+type BatchItemResult struct {
+	CustomID string
+	Response ChatResponse
+	Err      error
+}
+
+// BatchJob describes a batch job submitted to a provider batch endpoint.
+type BatchJob struct {
+	ID             string         // Provider job ID (OpenAI batch ID or Gemini batch name)
+	Status         BatchJobStatus // Normalized status
+	ProviderStatus string         // Raw status reported by the provider
+	Total          int            // Number of requests, if reported
+	Completed      int            // Number of completed requests, if reported
+	Failed         int            // Number of failed requests, if reported
+}
+
+// BatchClient is implemented by clients that support the cheaper, asynchronous provider batch endpoints.
+type BatchClient interface {
+	// SubmitBatch uploads items and starts a batch job.
+	SubmitBatch(ctx context.Context, items []BatchItem) (BatchJob, error)
+	// GetBatch returns the current state of the job.
+	GetBatch(ctx context.Context, id string) (BatchJob, error)
+	// BatchResults downloads the results of a finished job, keyed by BatchItem.CustomID.
+	BatchResults(ctx context.Context, id string) (map[string]BatchItemResult, error)
+	// CancelBatch asks the provider to stop the job.
+	CancelBatch(ctx context.Context, id string) error
+}
+
+// WaitBatch polls the job every interval until it is done or ctx is cancelled.
+func WaitBatch(ctx context.Context, c BatchClient, id string, interval time.Duration) (BatchJob, error) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	for {
+		job, err := c.GetBatch(ctx, id)
+		if err != nil {
+			return job, err
+		}
+		if job.Status.Done() {
+			return job, nil
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return job, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package llm_client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	genai "google.golang.org/genai"
+)
+
+func batchItems() []BatchItem {
+	return []BatchItem{
+		{CustomID: "first", Request: ChatRequest{Messages: []Message{{Role: RoleUser, Content: "hello"}}}},
+		{CustomID: "second", Request: ChatRequest{Messages: []Message{{Role: RoleUser, Content: "bye"}}}},
+	}
+}
+
+func TestOpenAIBatch(t *testing.T) {
+	var uploaded string
+	polls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/files", func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		data, _ := io.ReadAll(f)
+		uploaded = string(data)
+		_ = json.NewEncoder(w).Encode(openai.File{ID: "file-in"})
+	})
+	mux.HandleFunc("POST /v1/batches", func(w http.ResponseWriter, r *http.Request) {
+		var req openai.CreateBatchRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "file-in", req.InputFileID)
+		assert.Equal(t, openai.BatchEndpointChatCompletions, req.Endpoint)
+		_ = json.NewEncoder(w).Encode(openai.Batch{ID: "batch_1", Status: "validating"})
+	})
+	mux.HandleFunc("GET /v1/batches/batch_1", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		b := openai.Batch{ID: "batch_1", Status: "in_progress"}
+		if polls > 1 {
+			out, errs := "file-out", "file-err"
+			b.Status, b.OutputFileID, b.ErrorFileID = "completed", &out, &errs
+			b.RequestCounts = openai.BatchRequestCounts{Total: 2, Completed: 1, Failed: 1}
+		}
+		_ = json.NewEncoder(w).Encode(b)
+	})
+	mux.HandleFunc("GET /v1/files/file-out/content", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"id":"r1","custom_id":"first","response":{"status_code":200,"body":{"choices":[{"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}},"error":null}`+"\n")
+	})
+	mux.HandleFunc("GET /v1/files/file-err/content", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"id":"r2","custom_id":"second","response":null,"error":{"code":"server_error","message":"overloaded"}}`+"\n")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config := openai.DefaultConfig("test")
+	config.BaseURL = server.URL + "/v1"
+	client := NewOpenAIClientFromConfig(config, "gpt-4o-mini")
+
+	var buf bytes.Buffer
+	require.NoError(t, client.WriteBatchFile(&buf, batchItems()))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var line openai.BatchChatCompletionRequest
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+	assert.Equal(t, "first", line.CustomID)
+	assert.Equal(t, "gpt-4o-mini", line.Body.Model)
+	assert.Equal(t, openai.BatchEndpointChatCompletions, line.URL)
+
+	ctx := context.Background()
+	job, err := client.SubmitBatch(ctx, batchItems())
+	require.NoError(t, err)
+	assert.Equal(t, BatchJob{ID: "batch_1", Status: BatchJobStatusPending, ProviderStatus: "validating"}, job)
+	assert.Equal(t, strings.TrimSpace(buf.String()), uploaded)
+
+	job, err = WaitBatch(ctx, client, job.ID, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, BatchJobStatusSucceeded, job.Status)
+	assert.Equal(t, 2, job.Total)
+
+	results, err := client.BatchResults(ctx, job.ID)
+	require.NoError(t, err)
+	require.NoError(t, results["first"].Err)
+	assert.Equal(t, "hi", results["first"].Response.Choices[0].Content)
+	assert.Equal(t, 4, results["first"].Response.Usage.TotalTokens)
+	assert.EqualError(t, results["second"].Err, "batch request failed: server_error: overloaded")
+
+	_, err = client.SubmitBatch(ctx, append(batchItems(), BatchItem{CustomID: "first"}))
+	assert.EqualError(t, err, `duplicate batch custom id "first"`)
+}
+
+func TestGeminiBatch(t *testing.T) {
+	var created map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1beta/models/gemini-test:batchGenerateContent", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+		_, _ = io.WriteString(w, `{"name":"batches/b1","metadata":{"state":"BATCH_STATE_PENDING"}}`)
+	})
+	mux.HandleFunc("GET /v1beta/batches/b1", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"name":"batches/b1","metadata":{"state":"BATCH_STATE_SUCCEEDED","output":{"inlinedResponses":{"inlinedResponses":[
+			{"metadata":{"custom_id":"first"},"response":{"candidates":[{"content":{"parts":[{"text":"hi"}]}}],"usageMetadata":{"promptTokenCount":2,"candidatesTokenCount":1,"totalTokenCount":3}}},
+			{"metadata":{"custom_id":"second"},"error":{"message":"quota"}}
+		]}}}}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := NewGoogleClient(ctx, &genai.ClientConfig{
+		APIKey:      "test",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	}, "gemini-test")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, client.WriteBatchFile(&buf, batchItems()))
+	var line geminiBatchLine
+	require.NoError(t, json.Unmarshal([]byte(strings.Split(buf.String(), "\n")[1]), &line))
+	assert.Equal(t, "second", line.Key)
+	assert.Equal(t, "bye", line.Request.Contents[0].Parts[0].Text)
+
+	job, err := client.SubmitBatch(ctx, batchItems())
+	require.NoError(t, err)
+	assert.Equal(t, "batches/b1", job.ID)
+	assert.Equal(t, BatchJobStatusPending, job.Status)
+	assert.Contains(t, created, "batch")
+
+	job, err = WaitBatch(ctx, client, job.ID, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, BatchJobStatusSucceeded, job.Status)
+
+	results, err := client.BatchResults(ctx, job.ID)
+	require.NoError(t, err)
+	require.NoError(t, results["first"].Err)
+	assert.Equal(t, "hi", results["first"].Response.Choices[0].Content)
+	assert.Equal(t, 3, results["first"].Response.Usage.TotalTokens)
+	assert.EqualError(t, results["second"].Err, "batch request failed: quota")
+
+	_, err = client.SubmitBatch(ctx, []BatchItem{
+		{CustomID: "a", Request: ChatRequest{Model: "gemini-a"}},
+		{CustomID: "b", Request: ChatRequest{Model: "gemini-b"}},
+	})
+	assert.Error(t, err)
+}
+
+func TestParseGeminiBatchOutput(t *testing.T) {
+	results := map[string]BatchItemResult{}
+	err := parseGeminiBatchOutput([]byte(`{"key":"a","response":{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}}
+{"key":"b","error":{"message":"bad request"}}
+`), results)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", results["a"].Response.Choices[0].Content)
+	assert.Error(t, results["b"].Err)
+}
@@ -0,0 +1,73 @@
+package llm_client
+
+import "time"
+
+// StopReason tells why a tool loop run ended.
+type StopReason string
+
+const (
+	StopFinalAnswer StopReason = "final_answer" // the model answered without calling a tool
+	StopExitTool    StopReason = "exit_tool"    // a tool with ExitFunc was called
+	StopMaxCalls    StopReason = "max_calls"    // ToolLoopOptions.MaxCalls was reached
+	StopError       StopReason = "error"        // the run failed, see the returned error
+	StopPaused      StopReason = "paused"       // a call waits for approval, see ResumeTools
+)
+
+// RunResult describes a finished tool loop run, see RunTools.
+type RunResult struct {
+	Answer     string        // final answer, or the formatted result of the exit tool
+	StopReason StopReason    // why the run ended
+	Messages   []Message     // full conversation plus the final answer, including messages Memory trimmed from requests
+	Usage      TokenUsage    // token usage summed over all rounds
+	Rounds     []TokenUsage  // token usage of every model response
+	Steps      []ToolStep    // executed tool calls in transcript order
+	ExitValues []interface{} // values returned by the exit tool, see ExitValue
+	CallCount  int           // function calls counted against ToolLoopOptions.MaxCalls
+	Pending    []PendingCall // calls of the paused turn, set with StopPaused
+}
+
+// ToolStep records one executed tool call.
+type ToolStep struct {
+	Round     int           // index of the model response that requested the call
+	ID        string        // call ID assigned by the model
+	Tool      string        // tool name
+	Arguments string        // JSON arguments sent by the model
+	Output    string        // result sent back to the model
+	Values    []interface{} `json:"-"` // values returned by the tool, nil if it did not run or was restored
+	Error     string        // set when the call failed
+	Duration  time.Duration // run time of the tool
+}
+
+func (c *toolCall) step(round int) ToolStep {
+	s := ToolStep{
+		Round:     round,
+		ID:        c.call.ID,
+		Tool:      c.call.Name,
+		Arguments: c.call.Arguments,
+		Output:    c.result,
+		Values:    c.values,
+		Duration:  c.took,
+	}
+	if c.err != nil {
+		s.Error = c.err.Error()
+	}
+	return s
+}
+
+// ExitValue returns the first value of type T returned by the exit tool.
+func ExitValue[T any](r *RunResult) (T, bool) {
+	for _, v := range r.ExitValues {
+		if typed, ok := v.(T); ok {
+			return typed, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// add accumulates o into u.
+func (u *TokenUsage) add(o TokenUsage) {
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.TotalTokens += o.TotalTokens
+}
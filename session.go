@@ -0,0 +1,436 @@
+package llm_client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+)
+
+var (
+	// ErrSessionNotFound is returned by a SessionStore for an unknown session ID.
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrTurnNotFound is returned when a turn ID does not belong to the session.
+	ErrTurnNotFound = errors.New("turn not found")
+)
+
+// Turn is one stored message of a session. Turns form a tree: editing a turn adds a sibling
+// with the same parent, so every branch of the conversation is kept.
+type Turn struct {
+	ID       string    `json:"id"`
+	ParentID string    `json:"parent_id,omitempty"` // empty for the first message
+	Message  Message   `json:"message"`
+	Created  time.Time `json:"created"`
+}
+
+// SessionInfo describes a stored session.
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title,omitempty"`
+	Head       string    `json:"head,omitempty"`        // last turn of the active branch
+	ForkedFrom string    `json:"forked_from,omitempty"` // session this one was forked from
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+}
+
+// SessionStore persists sessions and their turns. Turns are only ever appended.
+type SessionStore interface {
+	// SaveSession creates or replaces the session info.
+	SaveSession(ctx context.Context, info SessionInfo) error
+	// AppendTurns adds turns to an existing session.
+	AppendTurns(ctx context.Context, sessionID string, turns ...Turn) error
+	// LoadSession returns ErrSessionNotFound for unknown ids. Turns are in the order they were appended.
+	LoadSession(ctx context.Context, id string) (SessionInfo, []Turn, error)
+	// ListSessions returns all sessions, most recently updated first.
+	ListSessions(ctx context.Context) ([]SessionInfo, error)
+}
+
+// Session owns a conversation history and persists every change to its store.
+// The active branch runs from the first message to Head, Messages returns it.
+type Session struct {
+	mu    sync.Mutex
+	store SessionStore
+	info  SessionInfo
+	turns map[string]Turn
+	order []string // turn IDs in creation order
+}
+
+// NewSession creates an empty session, a random ID is used when id is empty.
+func NewSession(ctx context.Context, store SessionStore, id string) (*Session, error) {
+	if id == "" {
+		id = newID()
+	}
+	now := time.Now()
+	s := &Session{
+		store: store,
+		info:  SessionInfo{ID: id, Created: now, Updated: now},
+		turns: map[string]Turn{},
+	}
+	if err := store.SaveSession(ctx, s.info); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadSession restores a session from store.
+func LoadSession(ctx context.Context, store SessionStore, id string) (*Session, error) {
+	info, turns, err := store.LoadSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{store: store, info: info, turns: map[string]Turn{}}
+	for _, t := range turns {
+		s.turns[t.ID] = t
+		s.order = append(s.order, t.ID)
+	}
+	return s, nil
+}
+
+// ID returns the session ID.
+func (s *Session) ID() string {
+	return s.info.ID
+}
+
+// Info returns the session info.
+func (s *Session) Info() SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.info
+}
+
+// SetTitle changes the session title.
+func (s *Session) SetTitle(ctx context.Context, title string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := s.info
+	info.Title, info.Updated = title, time.Now()
+	return s.saveInfo(ctx, info)
+}
+
+// Head returns the ID of the last turn of the active branch, empty if the session has no messages.
+func (s *Session) Head() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.info.Head
+}
+
+// Messages returns the messages of the active branch, ready to be sent in a ChatRequest.
+func (s *Session) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.path(s.info.Head)
+	messages := make([]Message, len(path))
+	for i, t := range path {
+		messages[i] = t.Message
+	}
+	return messages
+}
+
+// Turns returns the turns of the active branch.
+func (s *Session) Turns() []Turn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.path(s.info.Head)
+}
+
+// Append adds messages to the end of the active branch and returns the ID of the last one.
+func (s *Session) Append(ctx context.Context, messages ...Message) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.appendTurns(ctx, s.info.Head, messages)
+}
+
+// Edit replaces the message of turn id on a new branch: the new message gets the same parent
+// as id and becomes the head, the old branch stays available through Alternatives and Checkout.
+func (s *Session) Edit(ctx context.Context, id string, m Message) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.turns[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrTurnNotFound, id)
+	}
+	return s.appendTurns(ctx, t.ParentID, []Message{m})
+}
+
+// Checkout makes the branch ending at turn id the active branch. When id has later turns the
+// most recent path below it is followed, so checking out an alternative resumes that branch.
+func (s *Session) Checkout(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.turns[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrTurnNotFound, id)
+	}
+	info := s.info
+	info.Head, info.Updated = s.latestLeaf(id), time.Now()
+	return s.saveInfo(ctx, info)
+}
+
+// Alternatives returns all versions of turn id, i.e. the turns sharing its parent, oldest first.
+func (s *Session) Alternatives(id string) ([]Turn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.turns[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTurnNotFound, id)
+	}
+	return s.children(t.ParentID), nil
+}
+
+// Branches returns the last turn of every branch, oldest first.
+func (s *Session) Branches() []Turn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var leaves []Turn
+	for _, id := range s.order {
+		if len(s.children(id)) == 0 {
+			leaves = append(leaves, s.turns[id])
+		}
+	}
+	return leaves
+}
+
+// History returns the active branch as llm_models messages. The other versions of each turn
+// that were created before it are attached as PreviousMessages, oldest first.
+func (s *Session) History() []llm_models.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.path(s.info.Head)
+	history := make([]llm_models.Message, len(path))
+	for i, t := range path {
+		history[i] = modelMessage(t.Message)
+		for _, alt := range s.children(t.ParentID) {
+			if alt.ID == t.ID {
+				break
+			}
+			prev := modelMessage(alt.Message)
+			history[i].PreviousMessages = append(history[i].PreviousMessages, &prev)
+		}
+	}
+	return history
+}
+
+// Fork copies the active branch into a new session in store, which may be the session's own
+// store. A random ID is used when id is empty.
+func (s *Session) Fork(ctx context.Context, store SessionStore, id string) (*Session, error) {
+	s.mu.Lock()
+	path := s.path(s.info.Head)
+	title, from := s.info.Title, s.info.ID
+	s.mu.Unlock()
+
+	fork, err := NewSession(ctx, store, id)
+	if err != nil {
+		return nil, err
+	}
+	fork.mu.Lock()
+	defer fork.mu.Unlock()
+	fork.info.Title, fork.info.ForkedFrom = title, from
+	// keep the turn IDs so both sessions can refer to the same point of the conversation
+	parent := ""
+	turns := make([]Turn, len(path))
+	for i, t := range path {
+		t.ParentID, parent = parent, t.ID
+		turns[i] = t
+	}
+	if len(turns) > 0 {
+		if err := store.AppendTurns(ctx, fork.info.ID, turns...); err != nil {
+			return nil, err
+		}
+		for _, t := range turns {
+			fork.turns[t.ID] = t
+			fork.order = append(fork.order, t.ID)
+		}
+		fork.info.Head = parent
+	}
+	if err := store.SaveSession(ctx, fork.info); err != nil {
+		return nil, err
+	}
+	return fork, nil
+}
+
+// RunTools appends messages to the active branch, runs the tool loop on it and stores the
+// transcript of the run, including the final answer. opts.Memory only trims the requests
+// sent to the model; the stored history stays complete.
+func (s *Session) RunTools(
+	ctx context.Context,
+	client AIClient,
+	messages []Message,
+	tools []llm_models.Tool,
+	opts ToolLoopOptions,
+) (*RunResult, error) {
+	if _, err := s.Append(ctx, messages...); err != nil {
+		return nil, err
+	}
+	history := s.Messages()
+	res, err := RunTools(ctx, client, history, tools, opts)
+	if res != nil && len(res.Messages) > len(history) {
+		if _, serr := s.Append(ctx, res.Messages[len(history):]...); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return res, err
+}
+
+func (s *Session) appendTurns(ctx context.Context, parent string, messages []Message) (string, error) {
+	if len(messages) == 0 {
+		return parent, nil
+	}
+	turns := make([]Turn, len(messages))
+	now := time.Now()
+	for i, m := range messages {
+		turns[i] = Turn{ID: newID(), ParentID: parent, Message: m, Created: now}
+		parent = turns[i].ID
+	}
+	if err := s.store.AppendTurns(ctx, s.info.ID, turns...); err != nil {
+		return "", err
+	}
+	for _, t := range turns {
+		s.turns[t.ID] = t
+		s.order = append(s.order, t.ID)
+	}
+	info := s.info
+	info.Head, info.Updated = parent, now
+	return parent, s.saveInfo(ctx, info)
+}
+
+func (s *Session) saveInfo(ctx context.Context, info SessionInfo) error {
+	if err := s.store.SaveSession(ctx, info); err != nil {
+		return err
+	}
+	s.info = info
+	return nil
+}
+
+// path returns the turns from the first message to id.
+func (s *Session) path(id string) []Turn {
+	var path []Turn
+	for id != "" {
+		t, ok := s.turns[id]
+		if !ok {
+			break
+		}
+		path = append(path, t)
+		id = t.ParentID
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// children returns the turns with the given parent in creation order.
+func (s *Session) children(parent string) []Turn {
+	var children []Turn
+	for _, id := range s.order {
+		if t := s.turns[id]; t.ParentID == parent {
+			children = append(children, t)
+		}
+	}
+	return children
+}
+
+// latestLeaf follows the most recently created child from id to the end of its branch.
+func (s *Session) latestLeaf(id string) string {
+	for {
+		children := s.children(id)
+		if len(children) == 0 {
+			return id
+		}
+		id = children[len(children)-1].ID
+	}
+}
+
+func modelMessage(m Message) llm_models.Message {
+	return llm_models.Message{
+		Name:         m.Name,
+		Content:      m.Content,
+		Role:         llm_models.Role(m.Role),
+		MultiContent: m.MultiContent,
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// MemorySessionStore keeps sessions in memory.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]SessionInfo
+	turns    map[string][][]byte // JSON of every turn per session, so callers cannot change stored turns
+}
+
+// NewMemorySessionStore creates an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]SessionInfo{}, turns: map[string][][]byte{}}
+}
+
+func (s *MemorySessionStore) SaveSession(ctx context.Context, info SessionInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[info.ID] = info
+	return nil
+}
+
+func (s *MemorySessionStore) AppendTurns(ctx context.Context, sessionID string, turns ...Turn) error {
+	added := make([][]byte, len(turns))
+	for i, t := range turns {
+		data, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		added[i] = data
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[sessionID]; !ok {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	s.turns[sessionID] = append(s.turns[sessionID], added...)
+	return nil
+}
+
+func (s *MemorySessionStore) LoadSession(ctx context.Context, id string) (SessionInfo, []Turn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.sessions[id]
+	if !ok {
+		return SessionInfo{}, nil, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	var turns []Turn
+	for _, data := range s.turns[id] {
+		var t Turn
+		if err := json.Unmarshal(data, &t); err != nil {
+			return SessionInfo{}, nil, err
+		}
+		turns = append(turns, t)
+	}
+	return info, turns, nil
+}
+
+func (s *MemorySessionStore) ListSessions(ctx context.Context) ([]SessionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]SessionInfo, 0, len(s.sessions))
+	for _, info := range s.sessions {
+		list = append(list, info)
+	}
+	sortSessions(list)
+	return list, nil
+}
+
+func sortSessions(list []SessionInfo) {
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Updated.Equal(list[j].Updated) {
+			return list[i].Updated.After(list[j].Updated)
+		}
+		return list[i].ID < list[j].ID
+	})
+}
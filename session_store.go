@@ -0,0 +1,324 @@
+package llm_client
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JSONLSessionStore keeps every session as <Dir>/<id>.jsonl. Each line holds either the session
+// info, the last one wins, or a turn, so changes are appended and never rewrite the file.
+type JSONLSessionStore struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+// sessionRecord is a single line of a session file.
+type sessionRecord struct {
+	Session *SessionInfo `json:"session,omitempty"`
+	Turn    *Turn        `json:"turn,omitempty"`
+}
+
+func (s *JSONLSessionStore) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid session id %q", id)
+	}
+	return filepath.Join(s.Dir, id+".jsonl"), nil
+}
+
+func (s *JSONLSessionStore) SaveSession(ctx context.Context, info SessionInfo) error {
+	path, err := s.path(info.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	return s.append(path, os.O_CREATE, sessionRecord{Session: &info})
+}
+
+func (s *JSONLSessionStore) AppendTurns(ctx context.Context, sessionID string, turns ...Turn) error {
+	path, err := s.path(sessionID)
+	if err != nil {
+		return err
+	}
+	records := make([]sessionRecord, len(turns))
+	for i := range turns {
+		records[i] = sessionRecord{Turn: &turns[i]}
+	}
+	err = s.append(path, 0, records...)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	return err
+}
+
+func (s *JSONLSessionStore) append(path string, flag int, records ...sessionRecord) error {
+	var data []byte
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|flag, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *JSONLSessionStore) LoadSession(ctx context.Context, id string) (SessionInfo, []Turn, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return SessionInfo{}, nil, err
+	}
+	info, turns, err := s.read(path, true)
+	if errors.Is(err, os.ErrNotExist) {
+		return SessionInfo{}, nil, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	return info, turns, err
+}
+
+func (s *JSONLSessionStore) ListSessions(ctx context.Context) ([]SessionInfo, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []SessionInfo
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".jsonl" {
+			continue
+		}
+		info, _, err := s.read(filepath.Join(s.Dir, e.Name()), false)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, info)
+	}
+	sortSessions(list)
+	return list, nil
+}
+
+// read parses a session file, turns are only decoded when withTurns is set.
+func (s *JSONLSessionStore) read(path string, withTurns bool) (SessionInfo, []Turn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Open(path)
+	if err != nil {
+		return SessionInfo{}, nil, err
+	}
+	defer f.Close()
+
+	var info SessionInfo
+	var turns []Turn
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r sessionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return SessionInfo{}, nil, fmt.Errorf("invalid session file %s line %d: %w", path, line, err)
+		}
+		switch {
+		case r.Session != nil:
+			info = *r.Session
+		case r.Turn != nil && withTurns:
+			turns = append(turns, *r.Turn)
+		}
+	}
+	return info, turns, scanner.Err()
+}
+
+// SQLSessionStore keeps sessions in a database through database/sql. Sessions and turns are
+// stored as JSON documents in two tables, see CreateTables.
+type SQLSessionStore struct {
+	DB *sql.DB
+	// Dollar uses $1, $2, ... placeholders (PostgreSQL) instead of ? (SQLite, MySQL).
+	Dollar bool
+	// MySQL writes upserts as ON DUPLICATE KEY UPDATE instead of ON CONFLICT (SQLite, PostgreSQL).
+	MySQL bool
+	// Prefix is prepended to the table names "sessions" and "session_turns".
+	Prefix string
+}
+
+// CreateTables creates the tables used by the store if they do not exist.
+func (s *SQLSessionStore) CreateTables(ctx context.Context) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.Prefix + `sessions (
+			id VARCHAR(255) PRIMARY KEY,
+			data TEXT NOT NULL,
+			turn_count INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + s.Prefix + `session_turns (
+			session_id VARCHAR(255) NOT NULL,
+			seq INTEGER NOT NULL,
+			data TEXT NOT NULL,
+			PRIMARY KEY (session_id, seq)
+		)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := s.DB.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// query fills in the table prefix and rewrites ? placeholders for the configured database.
+func (s *SQLSessionStore) query(q string) string {
+	q = strings.ReplaceAll(q, "{prefix}", s.Prefix)
+	if !s.Dollar {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *SQLSessionStore) SaveSession(ctx context.Context, info SessionInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	q := `INSERT INTO {prefix}sessions (id, data) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET data = excluded.data`
+	if s.MySQL {
+		q = `INSERT INTO {prefix}sessions (id, data) VALUES (?, ?) ON DUPLICATE KEY UPDATE data = VALUES(data)`
+	}
+	_, err = s.DB.ExecContext(ctx, s.query(q), info.ID, string(data))
+	return err
+}
+
+// AppendTurns numbers the turns with the turn count of the session. Raising the count first
+// locks the session row, so concurrent appends to a session wait for each other.
+func (s *SQLSessionStore) AppendTurns(ctx context.Context, sessionID string, turns ...Turn) error {
+	if len(turns) == 0 {
+		var exists int
+		err := s.DB.QueryRowContext(ctx, s.query(`SELECT COUNT(*) FROM {prefix}sessions WHERE id = ?`), sessionID).Scan(&exists)
+		if err == nil && exists == 0 {
+			err = fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+		}
+		return err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx, s.query(`UPDATE {prefix}sessions SET turn_count = turn_count + ? WHERE id = ?`),
+		len(turns), sessionID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	var count int
+	err = tx.QueryRowContext(ctx, s.query(`SELECT turn_count FROM {prefix}sessions WHERE id = ?`), sessionID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	seq := count - len(turns)
+	for _, t := range turns {
+		data, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		seq++
+		_, err = tx.ExecContext(ctx, s.query(`INSERT INTO {prefix}session_turns (session_id, seq, data) VALUES (?, ?, ?)`),
+			sessionID, seq, string(data))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLSessionStore) LoadSession(ctx context.Context, id string) (SessionInfo, []Turn, error) {
+	var data string
+	err := s.DB.QueryRowContext(ctx, s.query(`SELECT data FROM {prefix}sessions WHERE id = ?`), id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SessionInfo{}, nil, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	if err != nil {
+		return SessionInfo{}, nil, err
+	}
+	var info SessionInfo
+	if err := json.Unmarshal([]byte(data), &info); err != nil {
+		return SessionInfo{}, nil, fmt.Errorf("invalid session %s: %w", id, err)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, s.query(`SELECT data FROM {prefix}session_turns WHERE session_id = ? ORDER BY seq`), id)
+	if err != nil {
+		return SessionInfo{}, nil, err
+	}
+	defer rows.Close()
+	var turns []Turn
+	for rows.Next() {
+		var t Turn
+		if err := rows.Scan(&data); err != nil {
+			return SessionInfo{}, nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &t); err != nil {
+			return SessionInfo{}, nil, fmt.Errorf("invalid turn of session %s: %w", id, err)
+		}
+		turns = append(turns, t)
+	}
+	return info, turns, rows.Err()
+}
+
+func (s *SQLSessionStore) ListSessions(ctx context.Context) ([]SessionInfo, error) {
+	rows, err := s.DB.QueryContext(ctx, s.query(`SELECT data FROM {prefix}sessions`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []SessionInfo
+	for rows.Next() {
+		var data string
+		var info SessionInfo
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &info); err != nil {
+			return nil, fmt.Errorf("invalid session: %w", err)
+		}
+		list = append(list, info)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortSessions(list)
+	return list, nil
+}
@@ -0,0 +1,205 @@
+package llm_client
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteStore returns a SQLSessionStore on a new SQLite database file.
+func sqliteStore(t *testing.T) *SQLSessionStore {
+	dsn := "file:" + filepath.Join(t.TempDir(), "sessions.db") + "?_pragma=busy_timeout(5000)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	store := &SQLSessionStore{DB: db, Prefix: "llm_"}
+	require.NoError(t, store.CreateTables(context.Background()))
+	return store
+}
+
+func contents(messages []Message) []string {
+	var out []string
+	for _, m := range messages {
+		out = append(out, m.Content)
+	}
+	return out
+}
+
+func TestSessionBranching(t *testing.T) {
+	type TestCase struct {
+		Name  string
+		Store func(t *testing.T) SessionStore
+	}
+
+	tcs := []TestCase{
+		{Name: "memory", Store: func(t *testing.T) SessionStore { return NewMemorySessionStore() }},
+		{Name: "jsonl", Store: func(t *testing.T) SessionStore { return &JSONLSessionStore{Dir: t.TempDir()} }},
+		{Name: "sql", Store: func(t *testing.T) SessionStore { return sqliteStore(t) }},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctx := context.Background()
+			store := tc.Store(t)
+			s, err := NewSession(ctx, store, "chat-1")
+			require.NoError(t, err)
+			require.NoError(t, s.SetTitle(ctx, "trip"))
+
+			_, err = s.Append(ctx, Message{Role: RoleSystem, Content: "be brief"}, Message{Role: RoleUser, Content: "Paris?"})
+			require.NoError(t, err)
+			question := s.Turns()[1].ID
+			_, err = s.Append(ctx, Message{Role: RoleAssistant, Content: "France"})
+			require.NoError(t, err)
+
+			// the user edits the question, the first answer stays on its own branch
+			edited, err := s.Edit(ctx, question, Message{Role: RoleUser, Content: "Rome?"})
+			require.NoError(t, err)
+			_, err = s.Append(ctx, Message{Role: RoleAssistant, Content: "Italy"})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"be brief", "Rome?", "Italy"}, contents(s.Messages()))
+			assert.Len(t, s.Branches(), 2)
+
+			alts, err := s.Alternatives(edited)
+			require.NoError(t, err)
+			require.Len(t, alts, 2)
+			assert.Equal(t, "Paris?", alts[0].Message.Content)
+
+			history := s.History()
+			require.Len(t, history, 3)
+			require.Len(t, history[1].PreviousMessages, 1)
+			assert.Equal(t, "Paris?", history[1].PreviousMessages[0].Content)
+			assert.Empty(t, history[2].PreviousMessages)
+
+			// switching back follows the old branch to its end
+			require.NoError(t, s.Checkout(ctx, question))
+			assert.Equal(t, []string{"be brief", "Paris?", "France"}, contents(s.Messages()))
+
+			loaded, err := LoadSession(ctx, store, "chat-1")
+			require.NoError(t, err)
+			assert.Equal(t, s.Messages(), loaded.Messages())
+			assert.Equal(t, "trip", loaded.Info().Title)
+			assert.Len(t, loaded.Branches(), 2)
+
+			fork, err := loaded.Fork(ctx, store, "chat-2")
+			require.NoError(t, err)
+			_, err = fork.Append(ctx, Message{Role: RoleUser, Content: "and Lyon?"})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"be brief", "Paris?", "France", "and Lyon?"}, contents(fork.Messages()))
+			assert.Len(t, fork.Branches(), 1)
+
+			reloaded, err := LoadSession(ctx, store, "chat-2")
+			require.NoError(t, err)
+			assert.Equal(t, fork.Messages(), reloaded.Messages())
+			assert.Equal(t, "chat-1", reloaded.Info().ForkedFrom)
+
+			list, err := store.ListSessions(ctx)
+			require.NoError(t, err)
+			require.Len(t, list, 2)
+			assert.Equal(t, "chat-2", list[0].ID)
+
+			_, err = LoadSession(ctx, store, "missing")
+			assert.ErrorIs(t, err, ErrSessionNotFound)
+			_, err = s.Edit(ctx, "missing", Message{})
+			assert.ErrorIs(t, err, ErrTurnNotFound)
+		})
+	}
+}
+
+func TestSQLSessionStoreConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	store := sqliteStore(t)
+	assert.ErrorIs(t, store.AppendTurns(ctx, "chat", Turn{ID: "t"}), ErrSessionNotFound)
+	assert.ErrorIs(t, store.AppendTurns(ctx, "chat"), ErrSessionNotFound)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := range 20 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- store.SaveSession(ctx, SessionInfo{ID: "chat", Title: fmt.Sprint("title ", i)})
+		}()
+		go func() {
+			defer wg.Done()
+			// the session may not exist yet
+			if err := store.AppendTurns(ctx, "chat", Turn{ID: fmt.Sprint(i, "a")}, Turn{ID: fmt.Sprint(i, "b")}); !errors.Is(err, ErrSessionNotFound) {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, store.AppendTurns(ctx, "chat", Turn{ID: "last"}))
+	_, turns, err := store.LoadSession(ctx, "chat")
+	require.NoError(t, err)
+	require.NotEmpty(t, turns)
+	assert.Equal(t, "last", turns[len(turns)-1].ID)
+	// the turns of one append stay together
+	for i := 0; i < len(turns)-1; i += 2 {
+		assert.Equal(t, turns[i].ID[:len(turns[i].ID)-1]+"b", turns[i+1].ID)
+	}
+	list, err := store.ListSessions(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestSessionRunTools(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	s, err := NewSession(ctx, store, "")
+	require.NoError(t, err)
+
+	client := &fakeClient{responses: []Response{{Content: "hi"}, {Content: "fine"}}}
+	res, err := s.RunTools(ctx, client, []Message{{Role: RoleUser, Content: "hello"}}, nil, ToolLoopOptions{MaxCalls: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "hi", res.Answer)
+	_, err = s.RunTools(ctx, client, []Message{{Role: RoleUser, Content: "how are you?"}}, nil, ToolLoopOptions{MaxCalls: 1})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"hello"}, contents(client.calls[0]))
+	assert.Equal(t, []string{"hello", "hi", "how are you?"}, contents(client.calls[1]))
+	loaded, err := LoadSession(ctx, store, s.ID())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hello", "hi", "how are you?", "fine"}, contents(loaded.Messages()))
+}
+
+func TestSessionRunToolsMemory(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	s, err := NewSession(ctx, store, "")
+	require.NoError(t, err)
+	_, err = s.Append(ctx,
+		Message{Role: RoleUser, Content: "one two three four five six"},
+		Message{Role: RoleAssistant, Content: "seven eight nine ten eleven twelve"},
+	)
+	require.NoError(t, err)
+
+	client := &fakeClient{responses: []Response{{Content: "fine"}}}
+	_, err = s.RunTools(ctx, client, []Message{{Role: RoleUser, Content: "how are you?"}}, nil, ToolLoopOptions{
+		MaxCalls: 1,
+		Memory:   &Memory{MaxTokens: 8, Counter: wordCounter},
+	})
+	require.NoError(t, err)
+
+	// the request was trimmed, the stored history is not
+	assert.Equal(t, []string{"how are you?"}, contents(client.calls[0]))
+	loaded, err := LoadSession(ctx, store, s.ID())
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"one two three four five six",
+		"seven eight nine ten eleven twelve",
+		"how are you?",
+		"fine",
+	}, contents(loaded.Messages()))
+}
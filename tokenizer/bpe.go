@@ -0,0 +1,168 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Encoding is a byte pair encoding (tiktoken compatible) tokenizer.
+type Encoding struct {
+	name    string
+	pattern *regexp.Regexp
+	ranks   map[string]int
+	tokens  [][]byte // token id -> bytes, used by Decode
+}
+
+// newEncoding parses a gzipped .tiktoken vocabulary ("<base64 token> <rank>" per line).
+func newEncoding(name string, pattern *regexp.Regexp, vocab io.Reader) (*Encoding, error) {
+	zr, err := gzip.NewReader(vocab)
+	if err != nil {
+		return nil, fmt.Errorf("read %s vocabulary: %w", name, err)
+	}
+	defer zr.Close()
+
+	e := &Encoding{name: name, pattern: pattern, ranks: make(map[string]int, 200000)}
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		sep := bytes.IndexByte(line, ' ')
+		if sep < 0 {
+			continue
+		}
+		token, err := base64.StdEncoding.DecodeString(string(line[:sep]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s token %q: %w", name, line[:sep], err)
+		}
+		rank, err := strconv.Atoi(string(line[sep+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s rank %q: %w", name, line[sep+1:], err)
+		}
+		e.ranks[string(token)] = rank
+		for len(e.tokens) <= rank {
+			e.tokens = append(e.tokens, nil)
+		}
+		e.tokens[rank] = token
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s vocabulary: %w", name, err)
+	}
+	return e, nil
+}
+
+// Name returns the encoding name, e.g. "cl100k_base".
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// Count returns the number of tokens in text.
+func (e *Encoding) Count(text string) int {
+	n := 0
+	e.split(text, func(piece []byte) {
+		if _, ok := e.ranks[string(piece)]; ok {
+			n++
+			return
+		}
+		n += len(e.merge(piece))
+	})
+	return n
+}
+
+// Encode returns the token ids of text.
+func (e *Encoding) Encode(text string) []int {
+	var out []int
+	e.split(text, func(piece []byte) {
+		if rank, ok := e.ranks[string(piece)]; ok {
+			out = append(out, rank)
+			return
+		}
+		for _, part := range e.merge(piece) {
+			out = append(out, e.ranks[string(part)])
+		}
+	})
+	return out
+}
+
+// Decode converts token ids back to text, unknown ids are skipped.
+func (e *Encoding) Decode(tokens []int) string {
+	var buf bytes.Buffer
+	for _, t := range tokens {
+		if t >= 0 && t < len(e.tokens) {
+			buf.Write(e.tokens[t])
+		}
+	}
+	return buf.String()
+}
+
+// split runs the pre-tokenizer and calls fn for every piece.
+// Go's regexp has no lookahead, so the "\s+(?!\S)" alternative of the tiktoken patterns is
+// emulated: a whitespace run followed by a non-space gives its last character to the next piece.
+func (e *Encoding) split(text string, fn func(piece []byte)) {
+	for len(text) > 0 {
+		loc := e.pattern.FindStringIndex(text)
+		if loc == nil {
+			fn([]byte(text))
+			return
+		}
+		end := loc[1]
+		piece := text[loc[0]:end]
+		if end < len(text) && isSpaces(piece) && !hasLineBreak(piece) {
+			if _, size := utf8.DecodeLastRuneInString(piece); size < len(piece) {
+				end -= size
+			}
+		}
+		if loc[0] > 0 {
+			// the patterns match every character, this only guards against surprises
+			fn([]byte(text[:loc[0]]))
+		}
+		fn([]byte(text[loc[0]:end]))
+		text = text[end:]
+	}
+}
+
+// merge applies the byte pair merges to a piece that is not a token on its own.
+func (e *Encoding) merge(piece []byte) [][]byte {
+	// bounds[i] is the start of part i, the last entry is len(piece)
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := e.ranks[string(piece[bounds[i]:bounds[i+2]])]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	parts := make([][]byte, len(bounds)-1)
+	for i := range parts {
+		parts[i] = piece[bounds[i]:bounds[i+1]]
+	}
+	return parts
+}
+
+func isSpaces(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasLineBreak(s string) bool {
+	return bytes.ContainsAny([]byte(s), "\r\n")
+}
@@ -0,0 +1,39 @@
+package tokenizer
+
+import "unicode/utf8"
+
+// Counter counts the tokens of a text.
+type Counter interface {
+	Count(text string) int
+}
+
+// Estimator approximates token counts for providers without a public offline tokenizer
+// (e.g. Gemini), counting CharsPerToken characters as one token.
+type Estimator struct {
+	CharsPerToken float64 // defaults to 4
+}
+
+// Count returns the estimated number of tokens in text, rounded up.
+func (e Estimator) Count(text string) int {
+	chars := utf8.RuneCountInString(text)
+	if chars == 0 {
+		return 0
+	}
+	per := e.CharsPerToken
+	if per <= 0 {
+		per = 4
+	}
+	n := int(float64(chars)/per + 0.999)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// ForModel returns the exact BPE encoding for known OpenAI models and an Estimator otherwise.
+func ForModel(model string) Counter {
+	if e, err := EncodingForModel(model); err == nil {
+		return e
+	}
+	return Estimator{}
+}
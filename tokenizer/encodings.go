@@ -0,0 +1,96 @@
+package tokenizer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// assets holds the gzipped tiktoken vocabularies, see https://github.com/openai/tiktoken.
+//
+//go:embed assets/*.tiktoken.gz
+var assets embed.FS
+
+const (
+	Cl100kBase = "cl100k_base"
+	O200kBase  = "o200k_base"
+)
+
+// The pre-tokenizer patterns of tiktoken without the "\s+(?!\S)" alternative, see Encoding.split.
+var patterns = map[string]string{
+	Cl100kBase: `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`,
+	O200kBase: strings.Join([]string{
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+		`\p{N}{1,3}`,
+		` ?[^\s\p{L}\p{N}]+[\r\n/]*`,
+		`\s*[\r\n]+`,
+		`\s+`,
+	}, "|"),
+}
+
+var (
+	encodingsMu sync.Mutex
+	encodings   = map[string]*Encoding{}
+)
+
+// GetEncoding returns the named encoding, loading its embedded vocabulary on first use.
+func GetEncoding(name string) (*Encoding, error) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	if e, ok := encodings[name]; ok {
+		return e, nil
+	}
+	pattern, ok := patterns[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %s", name)
+	}
+	data, err := assets.ReadFile("assets/" + name + ".tiktoken.gz")
+	if err != nil {
+		return nil, err
+	}
+	e, err := newEncoding(name, regexp.MustCompile(pattern), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	encodings[name] = e
+	return e, nil
+}
+
+// modelEncodings maps model name prefixes to their encoding, longer prefixes take precedence.
+var modelEncodings = map[string]string{
+	"gpt-4o":                 O200kBase,
+	"gpt-4.1":                O200kBase,
+	"gpt-4.5":                O200kBase,
+	"gpt-5":                  O200kBase,
+	"o1":                     O200kBase,
+	"o3":                     O200kBase,
+	"o4":                     O200kBase,
+	"gpt-4":                  Cl100kBase,
+	"gpt-3.5-turbo":          Cl100kBase,
+	"text-embedding-3":       Cl100kBase,
+	"text-embedding-ada-002": Cl100kBase,
+}
+
+// EncodingNameForModel returns the encoding used by an OpenAI model, or "" if unknown.
+func EncodingNameForModel(model string) string {
+	best, name := "", ""
+	for prefix, enc := range modelEncodings {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best, name = prefix, enc
+		}
+	}
+	return name
+}
+
+// EncodingForModel returns the encoding used by an OpenAI model.
+func EncodingForModel(model string) (*Encoding, error) {
+	name := EncodingNameForModel(model)
+	if name == "" {
+		return nil, fmt.Errorf("no encoding known for model %s", model)
+	}
+	return GetEncoding(name)
+}
@@ -0,0 +1,68 @@
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	type TestCase struct {
+		Name     string
+		Encoding string
+		Text     string
+		Expected []int
+	}
+
+	tcs := []TestCase{
+		{Name: "cl100k hello", Encoding: Cl100kBase, Text: "hello world", Expected: []int{15339, 1917}},
+		{Name: "cl100k punctuation", Encoding: Cl100kBase, Text: "tiktoken is great!", Expected: []int{83, 1609, 5963, 374, 2294, 0}},
+		{Name: "o200k hello", Encoding: O200kBase, Text: "hello world", Expected: []int{24912, 2375}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			e, err := GetEncoding(tc.Encoding)
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, e.Encode(tc.Text))
+			assert.Equal(t, len(tc.Expected), e.Count(tc.Text))
+			assert.Equal(t, tc.Text, e.Decode(tc.Expected))
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	texts := []string{
+		"",
+		"  leading spaces and trailing   ",
+		"line one\n\n  line two\r\nend",
+		"Unicode: héllo wörld, 日本語のテキスト, emoji 🎉🎉",
+		"func main() {\n\tfmt.Println(\"hi\")\n}\n",
+		"numbers 1234567 and 3.14159",
+	}
+	for _, name := range []string{Cl100kBase, O200kBase} {
+		e, err := GetEncoding(name)
+		require.NoError(t, err)
+		for _, text := range texts {
+			tokens := e.Encode(text)
+			assert.Equal(t, text, e.Decode(tokens), name)
+			assert.Equal(t, len(tokens), e.Count(text), name)
+		}
+	}
+}
+
+func TestForModel(t *testing.T) {
+	assert.Equal(t, O200kBase, EncodingNameForModel("gpt-4o-mini"))
+	assert.Equal(t, Cl100kBase, EncodingNameForModel("gpt-4-turbo"))
+	assert.Equal(t, "", EncodingNameForModel("gemini-2.5-flash"))
+
+	_, err := GetEncoding("p50k_base")
+	assert.Error(t, err)
+
+	enc, ok := ForModel("gpt-4o").(*Encoding)
+	require.True(t, ok)
+	assert.Equal(t, O200kBase, enc.Name())
+	assert.Equal(t, Estimator{}, ForModel("gemini-2.5-flash"))
+	assert.Equal(t, 3, Estimator{}.Count("twelve chars"))
+	assert.Equal(t, 0, Estimator{}.Count(""))
+}
@@ -0,0 +1,95 @@
+package llm_client
+
+import (
+	"encoding/json"
+
+	"github.com/HiroCloud/llm-client/tokenizer"
+)
+
+// Framing overhead of the OpenAI chat format, see
+// https://cookbook.openai.com/examples/how_to_count_tokens_with_tiktoken.
+const (
+	tokensPerMessage  = 3  // <|start|>{role}\n ... <|end|>
+	tokensPerName     = 1  // an explicit name replaces part of the role header
+	tokensReplyPrimer = 3  // every reply is primed with <|start|>assistant<|message|>
+	tokensPerFunction = 8  // name/description/parameter framing of one function definition
+	tokensToolsBlock  = 12 // the namespace wrapping all function definitions
+)
+
+// MessageTokenCounter returns a TokenCounter for model. OpenAI models are counted exactly
+// with their BPE encoding, other models are estimated (~4 characters per token).
+func MessageTokenCounter(model string) TokenCounter {
+	counter := tokenizer.ForModel(model)
+	return func(m Message) int {
+		return countMessage(counter, m)
+	}
+}
+
+func countMessage(counter tokenizer.Counter, m Message) int {
+	n := tokensPerMessage + counter.Count(m.Role) + counter.Count(m.Content)
+	if m.Name != "" {
+		n += tokensPerName + counter.Count(m.Name)
+	}
+	if m.FunctionCall != nil {
+		n += counter.Count(m.FunctionCall.Name) + counter.Count(m.FunctionCall.Arguments)
+	}
+	return n
+}
+
+// CountMessageTokens returns the prompt tokens used by messages, including the reply primer.
+func CountMessageTokens(model string, messages []Message) int {
+	return countMessages(tokenizer.ForModel(model), messages)
+}
+
+func countMessages(counter tokenizer.Counter, messages []Message) int {
+	n := tokensReplyPrimer
+	for _, m := range messages {
+		n += countMessage(counter, m)
+	}
+	return n
+}
+
+// CountFunctionTokens returns the prompt tokens used by function definitions.
+// Providers render the definitions in an undocumented format, so the result is an approximation.
+func CountFunctionTokens(model string, functions []FunctionDef) (int, error) {
+	return countFunctions(tokenizer.ForModel(model), functions)
+}
+
+func countFunctions(counter tokenizer.Counter, functions []FunctionDef) (int, error) {
+	if len(functions) == 0 {
+		return 0, nil
+	}
+	n := tokensToolsBlock
+	for _, fn := range functions {
+		n += tokensPerFunction + counter.Count(fn.Name) + counter.Count(fn.Description)
+		if fn.Parameters != nil {
+			params, err := json.Marshal(fn.Parameters)
+			if err != nil {
+				return 0, err
+			}
+			n += counter.Count(string(params))
+		}
+	}
+	return n, nil
+}
+
+// CountRequestTokens returns the prompt tokens req will use: messages, framing and functions.
+func CountRequestTokens(req ChatRequest) (int, error) {
+	return countRequest(tokenizer.ForModel(req.Model), req)
+}
+
+func countRequest(counter tokenizer.Counter, req ChatRequest) (int, error) {
+	fnTokens, err := countFunctions(counter, req.Functions)
+	if err != nil {
+		return 0, err
+	}
+	return countMessages(counter, req.Messages) + fnTokens, nil
+}
+
+// byteCounter counts the bytes of a text. Every token covers at least one byte, so it is a
+// cheap upper bound of the token count of any tokenizer.
+type byteCounter struct{}
+
+func (byteCounter) Count(text string) int {
+	return len(text)
+}
@@ -0,0 +1,50 @@
+package llm_client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountMessageTokens(t *testing.T) {
+	messages := []Message{{Role: RoleUser, Content: "hello world"}}
+	// 3 framing + "user" + "hello world" + 3 reply primer
+	assert.Equal(t, 9, CountMessageTokens("gpt-4o", messages))
+	assert.Equal(t, 9, CountMessageTokens("gpt-4", messages))
+
+	named := []Message{{Role: RoleFunction, Name: "lookup", Content: "hello world"}}
+	assert.Greater(t, CountMessageTokens("gpt-4o", named), 9)
+
+	// other providers are estimated
+	assert.Equal(t, 3+1+3+3, CountMessageTokens("gemini-2.5-flash", messages))
+
+	counter := MessageTokenCounter("gpt-4o")
+	assert.Equal(t, 6, counter(messages[0]))
+}
+
+func TestCountRequestTokens(t *testing.T) {
+	req := ChatRequest{
+		Model:    "gpt-4o-mini",
+		Messages: []Message{{Role: RoleUser, Content: "hello world"}},
+	}
+	n, err := CountRequestTokens(req)
+	require.NoError(t, err)
+	assert.Equal(t, 9, n)
+
+	req.Functions = []FunctionDef{{
+		Name:        "get_weather",
+		Description: "Get the weather of a city",
+		Parameters: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"city": map[string]any{"type": "string"}},
+		},
+	}}
+	withTools, err := CountRequestTokens(req)
+	require.NoError(t, err)
+	assert.Greater(t, withTools, n+tokensToolsBlock+tokensPerFunction)
+
+	req.Functions[0].Parameters = func() {}
+	_, err = CountRequestTokens(req)
+	assert.Error(t, err)
+}
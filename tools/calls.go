@@ -6,8 +6,20 @@ import (
 	"errors"
 	"fmt"
 	"github.com/HiroCloud/llm-client/llm_models"
+	"math"
 	"reflect"
+	"slices"
 	"strings"
+	"time"
+)
+
+var (
+	// ErrToolTimeout is returned when a tool runs longer than its Timeout.
+	ErrToolTimeout = errors.New("tool timed out")
+	// ErrToolPanic is returned when a tool function panics.
+	ErrToolPanic = errors.New("tool panicked")
+	// ErrInvalidArguments is returned when the arguments do not match the tool's parameters.
+	ErrInvalidArguments = errors.New("invalid arguments")
 )
 
 // CallJSONStr parses a JSON string and dispatches a call to the appropriate tool function using
@@ -18,7 +30,7 @@ func CallJSONStr(ctx context.Context, t *llm_models.Tool, jsonStr string) ([]int
 		data := map[string]interface{}{}
 		err := json.Unmarshal([]byte(jsonStr), &data)
 		if err != nil {
-			return nil, fmt.Errorf("json unmarshal fail: %w", err)
+			return nil, fmt.Errorf("%w: json unmarshal fail: %w", ErrInvalidArguments, err)
 		}
 		return CallToolMap(ctx, t, data)
 	} else if strings.HasPrefix(jsonStr, "[") {
@@ -26,37 +38,88 @@ func CallJSONStr(ctx context.Context, t *llm_models.Tool, jsonStr string) ([]int
 		var args []interface{}
 		err := json.Unmarshal([]byte(jsonStr), &args)
 		if err != nil {
-			return nil, fmt.Errorf("json unmarshal fail: %w", err)
+			return nil, fmt.Errorf("%w: json unmarshal fail: %w", ErrInvalidArguments, err)
 		}
 
 		return CallTool(ctx, t, args...)
 	}
 
-	return nil, errors.New("invalid json string")
+	return nil, fmt.Errorf("%w: invalid json string", ErrInvalidArguments)
 }
 
 // CallToolMap processes a map of parameters to invoke a specific tool function.
 // It ensures that parameters match the required order and all mandatory parameters are provided.
+// Optional parameters that are missing or null receive their default (see FuncDef.Defaults)
+// or the zero value of their type.
 // Returns the results of the tool execution or an error if validation or execution fails.
 func CallToolMap(ctx context.Context, t *llm_models.Tool, data map[string]interface{}) ([]interface{}, error) {
+	if t.Function.StructArgs {
+		return callStructArgs(ctx, t, data)
+	}
 	// Handle JSON object (named parameters)
 	// Ensure params are in the correct order
+	var params []reflect.Type
+	if t.CallFunc != nil {
+		params = funcParams(reflect.TypeOf(t.CallFunc))
+	}
 	var args []interface{}
-	for _, param := range t.Function.ParamOrder {
-		if val, ok := data[param]; ok {
+	for i, param := range t.Function.ParamOrder {
+		val, ok := data[param]
+		switch {
+		case ok && val != nil:
 			args = append(args, val)
-		} else {
-			return nil, fmt.Errorf("missing required parameter: %s", param)
+		case slices.Contains(t.Function.Parameters.Required, param):
+			if !ok {
+				return nil, fmt.Errorf("%w: missing required parameter: %s", ErrInvalidArguments, param)
+			}
+			args = append(args, val)
+		default:
+			if d, ok := t.Function.Defaults[param]; ok {
+				args = append(args, d)
+			} else if i < len(params) {
+				args = append(args, reflect.Zero(params[i]).Interface())
+			} else {
+				args = append(args, nil)
+			}
 		}
 	}
 
 	return CallTool(ctx, t, args...)
 }
 
+// callStructArgs checks the required fields and passes the arguments object, completed with
+// the defaults, to the function's struct parameter.
+func callStructArgs(ctx context.Context, t *llm_models.Tool, data map[string]interface{}) ([]interface{}, error) {
+	for _, param := range t.Function.Parameters.Required {
+		if _, ok := data[param]; !ok {
+			return nil, fmt.Errorf("%w: missing required parameter: %s", ErrInvalidArguments, param)
+		}
+	}
+	args := make(map[string]interface{}, len(data))
+	for name, d := range t.Function.Defaults {
+		args[name] = d
+	}
+	for name, v := range data {
+		if _, ok := t.Function.Defaults[name]; !ok || v != nil {
+			args[name] = v
+		}
+	}
+	return CallTool(ctx, t, args)
+}
+
 // CallTool invokes the provided tool function with the given arguments using reflection, handling optional context contexts.
 // Returns a slice of interface{} with the function results or an error if invocation fails.
-// Validates argument types and counts against the tool's function signature.
+// Validates argument types and counts against the tool's function signature, arguments decoded
+// from JSON are converted into the parameter types, see decodeArg.
+// A panic in the tool is returned as ErrToolPanic. When the tool has a Timeout, its context is
+// cancelled after it and ErrToolTimeout is returned without waiting for the function to return.
 func CallTool(ctx context.Context, t *llm_models.Tool, args ...interface{}) ([]interface{}, error) {
+	parent := ctx
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
 	funcValue := reflect.ValueOf(t.CallFunc)
 	funcType := funcValue.Type()
 
@@ -69,7 +132,7 @@ func CallTool(ctx context.Context, t *llm_models.Tool, args ...interface{}) ([]i
 	}
 
 	if len(args) != expectedArgCount {
-		return nil, fmt.Errorf("expected %d arguments, got %d", expectedArgCount, len(args))
+		return nil, fmt.Errorf("%w: expected %d arguments, got %d", ErrInvalidArguments, expectedArgCount, len(args))
 	}
 
 	// Prepare arguments for reflection call
@@ -84,15 +147,18 @@ func CallTool(ctx context.Context, t *llm_models.Tool, args ...interface{}) ([]i
 			expectedType = funcType.In(i + 1) // Adjust index if context is present
 		}
 
-		argValue := reflect.ValueOf(arg)
-		if !argValue.Type().ConvertibleTo(expectedType) {
-			return nil, fmt.Errorf("argument %d: expected %s, got %s", i, expectedType, argValue.Type())
+		argValue, err := decodeArg(arg, expectedType)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidArguments, argName(t, i), err)
 		}
-		callArgs = append(callArgs, argValue.Convert(expectedType))
+		callArgs = append(callArgs, argValue)
 	}
 
 	// Call the function
-	resp := funcValue.Call(callArgs)
+	resp, err := invoke(parent, ctx, funcValue, callArgs, t.Timeout)
+	if err != nil {
+		return nil, err
+	}
 
 	// Convert response to a slice of interfaces
 	var result []interface{}
@@ -113,3 +179,143 @@ func CallTool(ctx context.Context, t *llm_models.Tool, args ...interface{}) ([]i
 
 	return result, nil
 }
+
+// argName names argument i in errors.
+func argName(t *llm_models.Tool, i int) string {
+	if t.Function.StructArgs {
+		return "arguments"
+	}
+	if i < len(t.Function.ParamOrder) {
+		return "parameter " + t.Function.ParamOrder[i]
+	}
+	return fmt.Sprintf("argument %d", i)
+}
+
+// decodeArg converts arg into a value of type t. Values that are not assignable are converted
+// by re-marshalling them into t, so JSON objects become structs or maps, arrays typed slices and
+// strings time.Time, any encoding.TextUnmarshaler or an enum value by name, see RegisterEnum.
+// Numbers are only converted without loss.
+func decodeArg(arg interface{}, t reflect.Type) (reflect.Value, error) {
+	v := reflect.ValueOf(arg)
+	if !v.IsValid() {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, fmt.Errorf("expected %s, got null", t)
+	}
+	arg = decodeEnums(arg, t)
+	v = reflect.ValueOf(arg)
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+	if t.Kind() == reflect.Ptr {
+		elem, err := decodeArg(arg, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	}
+	if isNumber(v.Kind()) && isNumber(t.Kind()) {
+		return convertNumber(v, t)
+	}
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	ptr := reflect.New(t)
+	if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		return reflect.Value{}, fmt.Errorf("cannot decode %s into %s: %w", v.Type(), t, err)
+	}
+	return ptr.Elem(), nil
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// convertNumber converts v to t, rejecting fractions and values out of range.
+func convertNumber(v reflect.Value, t reflect.Type) (reflect.Value, error) {
+	var f float64
+	switch {
+	case v.CanInt():
+		f = float64(v.Int())
+	case v.CanUint():
+		f = float64(v.Uint())
+	default:
+		f = v.Float()
+	}
+	out := reflect.New(t).Elem()
+	switch {
+	case out.CanInt():
+		if v.CanFloat() && (f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64) {
+			return reflect.Value{}, fmt.Errorf("%v is not a valid %s", f, t)
+		}
+		if v.CanUint() && v.Uint() > math.MaxInt64 {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", v.Uint(), t)
+		}
+		n := v.Convert(reflect.TypeOf(int64(0))).Int()
+		if out.OverflowInt(n) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", n, t)
+		}
+		out.SetInt(n)
+	case out.CanUint():
+		if f < 0 || (v.CanFloat() && (f != math.Trunc(f) || f >= math.MaxUint64)) {
+			return reflect.Value{}, fmt.Errorf("%v is not a valid %s", f, t)
+		}
+		n := v.Convert(reflect.TypeOf(uint64(0))).Uint()
+		if out.OverflowUint(n) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", n, t)
+		}
+		out.SetUint(n)
+	default:
+		if out.OverflowFloat(f) {
+			return reflect.Value{}, fmt.Errorf("%v overflows %s", f, t)
+		}
+		out.SetFloat(f)
+	}
+	return out, nil
+}
+
+// invoke calls fn, turning panics into errors. With a timeout the call runs on its own
+// goroutine so it can be abandoned once ctx (parent limited by timeout) is done.
+func invoke(parent, ctx context.Context, fn reflect.Value, args []reflect.Value, timeout time.Duration) ([]reflect.Value, error) {
+	if timeout <= 0 {
+		return callRecover(fn, args)
+	}
+	type result struct {
+		resp []reflect.Value
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := callRecover(fn, args)
+		done <- result{resp, err}
+	}()
+	select {
+	case r := <-done:
+		return r.resp, r.err
+	case <-ctx.Done():
+		if err := parent.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w after %s", ErrToolTimeout, timeout)
+	}
+}
+
+func callRecover(fn reflect.Value, args []reflect.Value) (resp []reflect.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrToolPanic, r)
+		}
+	}()
+	return fn.Call(args), nil
+}
@@ -0,0 +1,333 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallToolPanic(t *testing.T) {
+	tool := &llm_models.Tool{CallFunc: func(s string) string {
+		panic("boom " + s)
+	}}
+	_, err := CallTool(context.Background(), tool, "now")
+	require.ErrorIs(t, err, ErrToolPanic)
+	assert.Contains(t, err.Error(), "boom now")
+
+	// also recovered when the call runs with a timeout
+	tool.Timeout = time.Second
+	_, err = CallTool(context.Background(), tool, "later")
+	assert.ErrorIs(t, err, ErrToolPanic)
+}
+
+func TestCallToolTimeout(t *testing.T) {
+	cancelled := make(chan struct{})
+	tool := &llm_models.Tool{
+		Timeout: 20 * time.Millisecond,
+		CallFunc: func(ctx context.Context) string {
+			<-ctx.Done()
+			close(cancelled)
+			return "too late"
+		},
+	}
+	_, err := CallTool(context.Background(), tool)
+	require.ErrorIs(t, err, ErrToolTimeout)
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("tool context was not cancelled")
+	}
+
+	// a tool that ignores its context is abandoned
+	tool.CallFunc = func() string {
+		time.Sleep(time.Second)
+		return "ignored"
+	}
+	start := time.Now()
+	_, err = CallTool(context.Background(), tool)
+	assert.ErrorIs(t, err, ErrToolTimeout)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// cancelling the caller is not reported as a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = CallTool(ctx, tool)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ErrToolTimeout)
+
+	tool.CallFunc = func() string { return "fast" }
+	resp, err := CallTool(context.Background(), tool)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"fast"}, resp)
+}
+
+func TestToolTimeoutJSON(t *testing.T) {
+	data, err := json.Marshal(llm_models.Tool{Function: llm_models.FuncDef{Name: "slow"}, Timeout: 1500 * time.Millisecond})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"timeout":"1.5s"`)
+
+	var tool llm_models.Tool
+	require.NoError(t, json.Unmarshal(data, &tool))
+	assert.Equal(t, "slow", tool.Function.Name)
+	assert.Equal(t, 1500*time.Millisecond, tool.Timeout)
+
+	// nanoseconds as written before are still read
+	require.NoError(t, json.Unmarshal([]byte(`{"function":{"name":"slow"},"timeout":5000000000}`), &tool))
+	assert.Equal(t, 5*time.Second, tool.Timeout)
+	assert.Error(t, json.Unmarshal([]byte(`{"timeout":"soon"}`), &tool))
+}
+
+type person struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestFormatResult(t *testing.T) {
+	type TestCase struct {
+		Name     string
+		Func     interface{}
+		Values   []interface{}
+		Expected string
+	}
+
+	tcs := []TestCase{
+		{Name: "struct and nil error", Func: func() (person, error) { return person{}, nil }, Values: []interface{}{person{"Alice", 30}, nil}, Expected: `{"name":"Alice","age":30}`},
+		{Name: "string", Func: func() string { return "" }, Values: []interface{}{"plain text"}, Expected: "plain text"},
+		{Name: "several values", Func: func() (int, bool) { return 0, false }, Values: []interface{}{1, true}, Expected: `[1,true]`},
+		{Name: "only error", Func: func() error { return nil }, Values: []interface{}{nil}, Expected: "null"},
+		{Name: "nil interface result", Func: func() interface{} { return nil }, Values: []interface{}{nil}, Expected: "null"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			out, err := FormatResult(&llm_models.Tool{CallFunc: tc.Func}, tc.Values)
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, out.Text)
+			assert.Empty(t, out.Parts)
+		})
+	}
+}
+
+func TestFormatResultFormatterAndParts(t *testing.T) {
+	tool := &llm_models.Tool{
+		CallFunc: func() (person, llm_models.ChatMessagePart, error) { return person{}, llm_models.ChatMessagePart{}, nil },
+		ResultFormatter: func(values []interface{}) (string, error) {
+			p := values[0].(person)
+			return p.Name + " is " + strconv.Itoa(p.Age), nil
+		},
+	}
+	image := ImagePart("image/png", []byte{1, 2, 3})
+	out, err := FormatResult(tool, []interface{}{person{"Bob", 41}, image, nil})
+	require.NoError(t, err)
+	assert.Equal(t, "Bob is 41", out.Text)
+	require.Len(t, out.Parts, 1)
+	assert.Equal(t, "data:image/png;base64,AQID", out.Parts[0].ImageURL.URL)
+
+	tool = &llm_models.Tool{CallFunc: func() []llm_models.ChatMessagePart { return nil }}
+	out, err = FormatResult(tool, []interface{}{[]llm_models.ChatMessagePart{ImageURLPart("https://example.com/a.png")}})
+	require.NoError(t, err)
+	assert.Equal(t, "", out.Text)
+	assert.Len(t, out.Parts, 1)
+
+	_, err = FormatResult(&llm_models.Tool{CallFunc: func() {}}, []interface{}{func() {}})
+	assert.Error(t, err)
+}
+
+// Search finds documents matching query.
+// limit: optional, default 10
+// lang: optional
+func Search(ctx context.Context, query string, limit int, lang string, page *int) string {
+	p := 0
+	if page != nil {
+		p = *page
+	}
+	return fmt.Sprintf("%s limit=%d lang=%q page=%d", query, limit, lang, p)
+}
+
+func TestOptionalParameters(t *testing.T) {
+	d, err := CreateDef(Search)
+	require.NoError(t, err)
+	assert.Equal(t, "Search finds documents matching query.", d.Function.Description)
+	assert.Equal(t, []string{"query"}, d.Function.Parameters.Required)
+	assert.Equal(t, map[string]interface{}{"limit": float64(10)}, d.Function.Defaults)
+	assert.Equal(t, "Defaults to 10.", d.Function.Parameters.Properties["limit"].Description)
+	assert.Equal(t, jsonschema.Integer, d.Function.Parameters.Properties["page"].Type)
+
+	type TestCase struct {
+		Name        string
+		Args        string
+		Expected    string
+		ExpectedErr string
+	}
+
+	tcs := []TestCase{
+		{Name: "only required", Args: `{"query": "go"}`, Expected: `go limit=10 lang="" page=0`},
+		{Name: "all", Args: `{"query": "go", "limit": 3, "lang": "en", "page": 2}`, Expected: `go limit=3 lang="en" page=2`},
+		{Name: "null optional", Args: `{"query": "go", "limit": null, "page": null}`, Expected: `go limit=10 lang="" page=0`},
+		{Name: "missing required", Args: `{"limit": 3}`, ExpectedErr: "missing required parameter: query"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			out, err := CallJSONStr(context.Background(), d, tc.Args)
+			if tc.ExpectedErr != "" {
+				assert.ErrorIs(t, err, ErrInvalidArguments)
+				assert.ErrorContains(t, err, tc.ExpectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []interface{}{tc.Expected}, out)
+		})
+	}
+}
+
+// Lookup finds a document by id.
+// Result: optional, default none
+func Lookup(id string) string {
+	return id
+}
+
+func TestOptionalAnnotationOtherName(t *testing.T) {
+	d, err := CreateDef(Lookup)
+	require.NoError(t, err)
+	assert.Equal(t, "Lookup finds a document by id.\nResult: optional, default none", d.Function.Description)
+	assert.Equal(t, []string{"id"}, d.Function.Parameters.Required)
+	assert.Nil(t, d.Function.Defaults)
+}
+
+type level string
+
+func (l *level) UnmarshalText(text []byte) error {
+	if string(text) != "low" && string(text) != "high" {
+		return fmt.Errorf("unknown level %q", text)
+	}
+	*l = level(text)
+	return nil
+}
+
+func TestCallToolDecodesArguments(t *testing.T) {
+	type filter struct {
+		Tags  []string  `json:"tags"`
+		Since time.Time `json:"since"`
+	}
+	tool := &llm_models.Tool{
+		Function: llm_models.FuncDef{ParamOrder: []string{"ids", "filter", "limits", "level", "max", "ratio"}},
+		CallFunc: func(ids []string, f *filter, limits map[string]int, l level, max uint8, ratio float32) string {
+			return fmt.Sprintf("%v %v %s %v %s %d %v", ids, f.Tags, f.Since.Format(time.DateOnly), limits, l, max, ratio)
+		},
+	}
+
+	type TestCase struct {
+		Name        string
+		Args        string
+		Expected    string
+		ExpectedErr string
+	}
+
+	tcs := []TestCase{
+		{
+			Name:     "Nested types",
+			Args:     `[["a", "b"], {"tags": ["x"], "since": "2024-05-01T00:00:00Z"}, {"a": 1}, "high", 200, 0.5]`,
+			Expected: "[a b] [x] 2024-05-01 map[a:1] high 200 0.5",
+		},
+		{
+			Name:        "Fraction into int",
+			Args:        `[["a"], {}, {}, "low", 3.7, 1]`,
+			ExpectedErr: "parameter max: 3.7 is not a valid uint8",
+		},
+		{
+			Name:        "Overflow",
+			Args:        `[["a"], {}, {}, "low", 256, 1]`,
+			ExpectedErr: "parameter max: 256 overflows uint8",
+		},
+		{
+			Name:        "Negative unsigned",
+			Args:        `[["a"], {}, {}, "low", -1, 1]`,
+			ExpectedErr: "parameter max: -1 is not a valid uint8",
+		},
+		{
+			Name:        "Wrong element type",
+			Args:        `[[1], {}, {}, "low", 1, 1]`,
+			ExpectedErr: "parameter ids: cannot decode []interface {} into []string",
+		},
+		{
+			Name:        "Text unmarshaler",
+			Args:        `[["a"], {}, {}, "medium", 1, 1]`,
+			ExpectedErr: `parameter level: cannot decode string into tools.level: unknown level "medium"`,
+		},
+		{
+			Name:        "Null",
+			Args:        `[["a"], {}, {}, "low", null, 1]`,
+			ExpectedErr: "parameter max: expected uint8, got null",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			out, err := CallJSONStr(context.Background(), tool, tc.Args)
+			if tc.ExpectedErr != "" {
+				assert.ErrorIs(t, err, ErrInvalidArguments)
+				assert.ErrorContains(t, err, tc.ExpectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []interface{}{tc.Expected}, out)
+		})
+	}
+}
+
+// SearchRequest describes a document search.
+type SearchRequest struct {
+	Query string   // text to look for
+	Tags  []string `json:",omitempty"` // only documents with all of these tags
+	Limit int      `json:",omitempty"`
+	page  int
+}
+
+// SearchResponse lists the matching documents.
+type SearchResponse struct {
+	Titles []string
+}
+
+// SearchDocuments runs a document search.
+func SearchDocuments(ctx context.Context, req SearchRequest) (SearchResponse, error) {
+	if req.Limit < 0 {
+		return SearchResponse{}, fmt.Errorf("negative limit")
+	}
+	return SearchResponse{Titles: []string{fmt.Sprintf("%s %v %d", req.Query, req.Tags, req.Limit)}}, nil
+}
+
+func TestStructArgs(t *testing.T) {
+	d, err := CreateDef(SearchDocuments)
+	require.NoError(t, err)
+	assert.True(t, d.Function.StructArgs)
+	assert.Equal(t, "SearchDocuments runs a document search.", d.Function.Description)
+	assert.Equal(t, []string{"Query", "Tags", "Limit"}, d.Function.ParamOrder)
+	assert.Equal(t, []string{"Query"}, d.Function.Parameters.Required)
+	assert.Equal(t, jsonschema.String, d.Function.Parameters.Properties["Query"].Type)
+	assert.Equal(t, "text to look for", d.Function.Parameters.Properties["Query"].Description)
+	assert.Equal(t, jsonschema.Array, d.Function.Parameters.Properties["Tags"].Type)
+
+	out, err := CallJSONStr(context.Background(), d, `{"Query": "go", "Tags": ["a", "b"], "Limit": 2}`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{SearchResponse{Titles: []string{"go [a b] 2"}}, nil}, out)
+
+	_, err = CallJSONStr(context.Background(), d, `{"Tags": ["a"]}`)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	assert.ErrorContains(t, err, "missing required parameter: Query")
+
+	_, err = CallJSONStr(context.Background(), d, `{"Query": "go", "Limit": 2.5}`)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	assert.ErrorContains(t, err, "arguments: cannot decode")
+
+	bound, err := NewTool(SearchDocuments, d.Function)
+	require.NoError(t, err)
+	assert.NotNil(t, bound.CallFunc)
+	_, err = NewTool(PrintTest, d.Function)
+	assert.ErrorIs(t, err, ErrInvalidDefinition)
+}
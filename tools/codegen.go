@@ -0,0 +1,216 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// GenerateOptions configures Generate.
+type GenerateOptions struct {
+	Package string // name of the package the Go file belongs to
+	PkgPath string // import path of that package, to name its types without a qualifier
+	Dir     string // directory of the package
+	JSONDir string // directory of the tool JSON files, relative to Dir so they can be embedded
+	File    string // name of the generated Go file in Dir
+	Func    string // name of the generated function returning the tools
+}
+
+// GenerateSymbol is a function or struct value to generate a tool for, Name is its identifier
+// in the package.
+type GenerateSymbol struct {
+	Name  string
+	Value interface{}
+}
+
+// Generate creates the definitions of symbols with CreateDef, saves them as JSON files and writes
+// a Go file that embeds them and binds each to its function with NewToolFromGenerated, so
+// binaries need no source at run time. The enums of non string types used by the functions
+// are registered with RegisterEnum. Tool names go through ToolName so every provider accepts
+// them. It is run by cmd/toolgen from a program importing the package.
+func Generate(opts GenerateOptions, symbols []GenerateSymbol) error {
+	if strings.HasPrefix(path.Clean(opts.JSONDir), "..") || path.IsAbs(opts.JSONDir) {
+		return fmt.Errorf("json directory %s must be inside the package directory to be embedded", opts.JSONDir)
+	}
+	data := codegenData{Package: opts.Package, Func: opts.Func}
+	imports := map[string]string{} // import path → name
+	seen := map[reflect.Type]bool{}
+	for _, s := range symbols {
+		t, err := CreateDef(s.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+		if t == nil {
+			return fmt.Errorf("%s: expected a function or a struct", s.Name)
+		}
+		t.Function.Name = ToolName(t.Function.Name)
+		file, err := SaveTool(path.Join(opts.Dir, opts.JSONDir), "", t)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+		value := s.Name
+		if t.CallFunc == nil {
+			value += "{}"
+		} else {
+			for _, param := range funcParams(reflect.TypeOf(s.Value)) {
+				data.Enums = collectEnums(data.Enums, param, opts.PkgPath, imports, seen)
+			}
+		}
+		data.Tools = append(data.Tools, codegenTool{
+			Var:   "toolgen" + s.Name,
+			File:  path.Join(opts.JSONDir, path.Base(file)),
+			Value: value,
+		})
+	}
+	for pkgPath, name := range imports {
+		data.Imports = append(data.Imports, codegenImport{Name: name, Path: pkgPath})
+	}
+	sort.Slice(data.Imports, func(i, j int) bool { return data.Imports[i].Path < data.Imports[j].Path })
+
+	var buf bytes.Buffer
+	if err := codegenTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("format generated code: %w", err)
+	}
+	return os.WriteFile(path.Join(opts.Dir, opts.File), src, 0644)
+}
+
+type codegenData struct {
+	Package string
+	Func    string
+	Imports []codegenImport
+	Enums   []codegenEnum
+	Tools   []codegenTool
+}
+
+type codegenImport struct {
+	Name string
+	Path string
+}
+
+type codegenEnum struct {
+	Type   string // type as written in the generated file
+	Values []string
+}
+
+type codegenTool struct {
+	Var   string // variable holding the embedded JSON
+	File  string // JSON file, relative to the package
+	Value string // function or struct value
+}
+
+// collectEnums appends the non string enum types reachable from t, they cannot be decoded
+// without their values. imports receives the packages of the types declared elsewhere.
+func collectEnums(enums []codegenEnum, t reflect.Type, pkgPath string, imports map[string]string, seen map[reflect.Type]bool) []codegenEnum {
+	if seen[t] {
+		return enums
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return collectEnums(enums, t.Elem(), pkgPath, imports, seen)
+	case reflect.Struct:
+		for _, field := range structFields(t) {
+			enums = collectEnums(enums, field.Type, pkgPath, imports, seen)
+		}
+		return enums
+	case reflect.String:
+		return enums
+	}
+	values := enumValues(t)
+	if len(values) == 0 {
+		return enums
+	}
+	name := t.Name()
+	if t.PkgPath() != pkgPath {
+		if !token.IsExported(name) {
+			return enums
+		}
+		name = importName(imports, t) + "." + name
+	}
+	e := codegenEnum{Type: name}
+	for _, v := range values {
+		if v.value.CanInt() {
+			e.Values = append(e.Values, fmt.Sprint(v.value.Int()))
+		} else {
+			e.Values = append(e.Values, fmt.Sprint(v.value.Uint()))
+		}
+	}
+	return append(enums, e)
+}
+
+// importName returns the name the package of t is imported as, unique within imports.
+func importName(imports map[string]string, t reflect.Type) string {
+	if name, ok := imports[t.PkgPath()]; ok {
+		return name
+	}
+	base, _, _ := strings.Cut(t.String(), ".")
+	name := base
+	for i := 2; ; i++ {
+		taken := name == "tools" || name == "llm_models" || name == "embed"
+		for _, existing := range imports {
+			taken = taken || existing == name
+		}
+		if !taken {
+			break
+		}
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	imports[t.PkgPath()] = name
+	return name
+}
+
+var codegenTemplate = template.Must(template.New("toolgen").Parse(`// Code generated by toolgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	_ "embed"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/HiroCloud/llm-client/tools"
+{{- range .Imports}}
+	{{.Name}} "{{.Path}}"
+{{- end}}
+)
+{{range .Tools}}
+//go:embed {{.File}}
+var {{.Var}} []byte
+{{end}}
+{{- if .Enums}}
+func init() {
+{{- range .Enums}}
+	tools.RegisterEnum[{{.Type}}]({{range $i, $v := .Values}}{{if $i}}, {{end}}{{$v}}{{end}})
+{{- end}}
+}
+{{end}}
+// {{.Func}} returns the generated tools bound to their functions.
+func {{.Func}}() ([]llm_models.Tool, error) {
+	var list []llm_models.Tool
+	for _, g := range []struct {
+		value interface{}
+		data  []byte
+	}{
+{{- range .Tools}}
+		{ {{- .Value}}, {{.Var}}},
+{{- end}}
+	} {
+		t, err := tools.NewToolFromGenerated(g.value, g.data)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+	return list, nil
+}
+`))
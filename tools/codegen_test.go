@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	opts := GenerateOptions{
+		Package: "tools",
+		PkgPath: "github.com/HiroCloud/llm-client/tools",
+		Dir:     dir,
+		JSONDir: "defs",
+		File:    "tools_gen.go",
+		Func:    "GeneratedTools",
+	}
+	err := Generate(opts, []GenerateSymbol{
+		{Name: "FileIssue", Value: FileIssue},
+		{Name: "PlanIssue", Value: PlanIssue},
+		{Name: "Ticket", Value: Ticket{}},
+	})
+	require.NoError(t, err)
+
+	src, err := os.ReadFile(path.Join(dir, "tools_gen.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "//go:embed defs/FileIssue.json\nvar toolgenFileIssue []byte")
+	assert.Contains(t, string(src), "tools.RegisterEnum[Urgency](0, 1, 2)\n}")
+	assert.Contains(t, string(src), "{Ticket{}, toolgenTicket},")
+	assert.Contains(t, string(src), "func GeneratedTools() ([]llm_models.Tool, error) {")
+
+	// the definitions are bound without reading the source
+	data, err := os.ReadFile(path.Join(dir, "defs", "FileIssue.json"))
+	require.NoError(t, err)
+	tool, err := NewToolFromGenerated(FileIssue, data)
+	require.NoError(t, err)
+	assert.Equal(t, "FileIssue", tool.Function.Name)
+	out, err := CallJSONStr(context.Background(), tool, `{"severity": "low", "urgency": "soon", "shade": "light", "count": 1}`)
+	require.NoError(t, err)
+	assert.Equal(t, "low 1 light 1", out[0])
+
+	_, err = NewToolFromGenerated(PlanIssue, data)
+	assert.ErrorIs(t, err, ErrInvalidDefinition)
+
+	opts.JSONDir = "../defs"
+	assert.ErrorContains(t, Generate(opts, nil), "must be inside the package directory")
+}
@@ -0,0 +1,324 @@
+package tools
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"go/constant"
+	"go/types"
+	"log"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+var (
+	stringerType        = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// enums caches the enum values registered with RegisterEnum and the constants found in packages.
+// The lock only guards the maps, packages are loaded without holding it.
+var enums = struct {
+	sync.Mutex
+	registered map[reflect.Type][]enumValue
+	packages   map[string]*packageEnums // package path → constants
+	mainDir    string                   // source directory of the main package, see noteMainDir
+}{
+	registered: map[reflect.Type][]enumValue{},
+	packages:   map[string]*packageEnums{},
+}
+
+// packageEnums holds the typed constants of a package, loaded once.
+type packageEnums struct {
+	once   sync.Once
+	consts map[string][]constant.Value // type name → constants
+}
+
+// enumValue is a value of an enum type and the name it has in schemas.
+type enumValue struct {
+	name  string
+	value reflect.Value
+}
+
+// RegisterEnum sets the values of an enum type, replacing the constants found in its package.
+// Schemas list the values as strings: string types by value, other types by their String
+// method, or fmt.Sprint without one. Arguments sent as such a name are decoded into the value.
+// Types of the standard library are only enums when registered.
+func RegisterEnum[T comparable](values ...T) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	var list []enumValue
+	for _, v := range values {
+		list = appendEnumValue(list, reflect.ValueOf(v))
+	}
+	enums.Lock()
+	defer enums.Unlock()
+	enums.registered[t] = list
+}
+
+// getEnumValuesForCustomType returns the enum names of t for a schema, loading the constants of
+// its package if needed, see loadEnumValues.
+func getEnumValuesForCustomType(t reflect.Type) []string {
+	var names []string
+	for _, v := range loadEnumValues(t) {
+		names = append(names, v.name)
+	}
+	return names
+}
+
+// enumValues returns the values of t known without loading packages: the registered ones or
+// the constants loaded when a schema used t. It is used when tools are bound and called.
+func enumValues(t reflect.Type) []enumValue {
+	return lookupEnumValues(t, false)
+}
+
+// loadEnumValues returns the values of a named string or integer type: the registered ones, or
+// the constants of that type declared in its package, in declaration order. Integer constants
+// are only used when the type has a String method naming them. Packages of the standard
+// library are not searched, so types like time.Duration are no enums.
+func loadEnumValues(t reflect.Type) []enumValue {
+	return lookupEnumValues(t, true)
+}
+
+func lookupEnumValues(t reflect.Type, load bool) []enumValue {
+	if t.Name() == "" || t.PkgPath() == "" || !isEnumKind(t.Kind()) {
+		return nil
+	}
+	enums.Lock()
+	registered, ok := enums.registered[t]
+	pkg := enums.packages[t.PkgPath()]
+	enums.Unlock()
+	if ok {
+		return registered
+	}
+	if t.Kind() != reflect.String && !t.Implements(stringerType) {
+		return nil
+	}
+	var consts map[string][]constant.Value
+	switch {
+	case load:
+		consts = packageConstants(t.PkgPath())
+	case pkg != nil:
+		// loaded by an earlier schema, or still loading and seen as empty
+		enums.Lock()
+		consts = pkg.consts
+		enums.Unlock()
+	}
+	var values []enumValue
+	for _, c := range consts[t.Name()] {
+		v, ok := constantValue(c, t)
+		if !ok {
+			continue
+		}
+		values = appendEnumValue(values, v)
+	}
+	return values
+}
+
+func isEnumKind(k reflect.Kind) bool {
+	return k == reflect.String || (isNumber(k) && k != reflect.Float32 && k != reflect.Float64)
+}
+
+// isStdPackage reports whether pkgPath belongs to the standard library: like in the go command,
+// its first element has no dot.
+func isStdPackage(pkgPath string) bool {
+	first, _, _ := strings.Cut(pkgPath, "/")
+	return pkgPath != "main" && !strings.Contains(first, ".")
+}
+
+// noteMainDir remembers the source directory of the main package from a function declared in
+// it, so its constants can be loaded independently of the working directory.
+func noteMainDir(f interface{}) {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil || !strings.HasPrefix(fn.Name(), "main.") {
+		return
+	}
+	file, _ := fn.FileLine(fn.Entry())
+	enums.Lock()
+	defer enums.Unlock()
+	enums.mainDir = filepath.Dir(file)
+}
+
+// appendEnumValue appends v to values unless its name is already listed.
+func appendEnumValue(values []enumValue, v reflect.Value) []enumValue {
+	var name string
+	switch {
+	case v.Kind() == reflect.String:
+		name = v.String()
+	case v.Type().Implements(stringerType):
+		name = v.Interface().(fmt.Stringer).String()
+	default:
+		name = fmt.Sprint(v.Interface())
+	}
+	for _, existing := range values {
+		if existing.name == name {
+			return values
+		}
+	}
+	return append(values, enumValue{name: name, value: v})
+}
+
+// constantValue converts a constant of a package into a value of t.
+func constantValue(c constant.Value, t reflect.Type) (reflect.Value, bool) {
+	switch c.Kind() {
+	case constant.String:
+		if t.Kind() == reflect.String {
+			return reflect.ValueOf(constant.StringVal(c)).Convert(t), true
+		}
+	case constant.Int:
+		if n, exact := constant.Int64Val(c); exact && t.Kind() != reflect.String {
+			v := reflect.New(t).Elem()
+			if v.CanInt() && !v.OverflowInt(n) {
+				v.SetInt(n)
+				return v, true
+			}
+			if v.CanUint() && n >= 0 && !v.OverflowUint(uint64(n)) {
+				v.SetUint(uint64(n))
+				return v, true
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// packageConstants loads the package at pkgPath once, including its test files, and returns its
+// typed constants by type name. The source must be available like for comments. The main
+// package is loaded from the directory noted by noteMainDir.
+func packageConstants(pkgPath string) map[string][]constant.Value {
+	if isStdPackage(pkgPath) {
+		return nil
+	}
+	enums.Lock()
+	pkg, ok := enums.packages[pkgPath]
+	if !ok {
+		pkg = &packageEnums{}
+		enums.packages[pkgPath] = pkg
+	}
+	mainDir := enums.mainDir
+	enums.Unlock()
+
+	pkg.once.Do(func() {
+		consts := loadPackageConstants(pkgPath, mainDir)
+		enums.Lock()
+		defer enums.Unlock()
+		pkg.consts = consts
+	})
+	enums.Lock()
+	defer enums.Unlock()
+	return pkg.consts
+}
+
+func loadPackageConstants(pkgPath, mainDir string) map[string][]constant.Value {
+	consts := map[string][]constant.Value{}
+	cfg := &packages.Config{Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax, Tests: true}
+	pattern := pkgPath
+	if pkgPath == "main" {
+		if mainDir == "" {
+			log.Printf("Error loading package main for enum values: source directory unknown")
+			return consts
+		}
+		cfg.Dir, pattern = mainDir, "."
+	}
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		log.Printf("Error loading package %s for enum values: %v", pkgPath, err)
+		return consts
+	}
+	for _, pkg := range pkgs {
+		// the test variant of a package also holds the constants of its _test.go files
+		if pkg.PkgPath != pkgPath && (pkgPath != "main" || pkg.Name != "main") {
+			continue
+		}
+		for _, e := range pkg.Errors {
+			log.Printf("Error loading package %s for enum values: %v", pkgPath, e)
+		}
+		// Only the package itself is type checked, imports are not loaded: the constants of an
+		// enum are declared next to their type and do not depend on them.
+		conf := types.Config{Error: func(error) {}}
+		checked, _ := conf.Check(pkgPath, pkg.Fset, pkg.Syntax, nil)
+
+		found := map[string][]*types.Const{}
+		scope := checked.Scope()
+		for _, name := range scope.Names() {
+			c, ok := scope.Lookup(name).(*types.Const)
+			if !ok {
+				continue
+			}
+			named, ok := c.Type().(*types.Named)
+			if !ok || named.Obj().Pkg() != checked {
+				continue
+			}
+			found[named.Obj().Name()] = append(found[named.Obj().Name()], c)
+		}
+		for typeName, list := range found {
+			if len(list) <= len(consts[typeName]) {
+				continue
+			}
+			sort.Slice(list, func(i, j int) bool { return list[i].Pos() < list[j].Pos() })
+			values := make([]constant.Value, len(list))
+			for i, c := range list {
+				values[i] = c.Val()
+			}
+			consts[typeName] = values
+		}
+	}
+	return consts
+}
+
+// decodeEnums replaces the enum names in arg, decoded from JSON, by the values of the non string
+// enum types of t they stand for, so arg can be unmarshalled into t.
+func decodeEnums(arg interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch a := arg.(type) {
+	case string:
+		if t.Kind() == reflect.String || reflect.PointerTo(t).Implements(textUnmarshalerType) ||
+			reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+			return arg
+		}
+		for _, v := range enumValues(t) {
+			if v.name == a {
+				return v.value.Interface()
+			}
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return arg
+		}
+		out := make([]interface{}, len(a))
+		for i, item := range a {
+			out[i] = decodeEnums(item, t.Elem())
+		}
+		return out
+	case map[string]interface{}:
+		fieldTypes := map[string]reflect.Type{}
+		switch t.Kind() {
+		case reflect.Struct:
+			for _, field := range structFields(t) {
+				fieldTypes[field.name] = field.Type
+			}
+		case reflect.Map:
+		default:
+			return arg
+		}
+		out := make(map[string]interface{}, len(a))
+		for k, v := range a {
+			if t.Kind() == reflect.Map {
+				out[k] = decodeEnums(v, t.Elem())
+			} else if ft, ok := fieldTypes[k]; ok {
+				out[k] = decodeEnums(v, ft)
+			} else {
+				out[k] = v
+			}
+		}
+		return out
+	}
+	return arg
+}
@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Severity string
+
+const (
+	SeverityLow  Severity = "low"
+	SeverityHigh Severity = "high"
+	// an alias of an existing value is listed once
+	SeverityDefault = SeverityLow
+)
+
+type Urgency int
+
+const (
+	UrgencyLater Urgency = iota
+	UrgencySoon
+	UrgencyNow
+)
+
+func (u Urgency) String() string {
+	return [...]string{"later", "soon", "now"}[u]
+}
+
+// Count has constants but no String method, it is not an enum.
+type Count int
+
+const (
+	CountOne Count = 1
+	CountTwo Count = 2
+)
+
+// Shade is a type without constants, its values are registered at runtime.
+type Shade string
+
+// FileIssue files an issue
+func FileIssue(severity Severity, urgency Urgency, shade Shade, count Count) string {
+	return fmt.Sprintf("%s %d %s %d", severity, urgency, shade, count)
+}
+
+// Triage describes how to handle an issue.
+type Triage struct {
+	Urgency  Urgency   `json:"urgency"`
+	Followup []Urgency `json:"followup,omitempty"`
+}
+
+// PlanIssue plans an issue
+func PlanIssue(triage Triage) string {
+	return fmt.Sprint(int(triage.Urgency), triage.Followup)
+}
+
+func TestEnumValues(t *testing.T) {
+	RegisterEnum[Shade]("light", "dark")
+
+	d, err := CreateDef(FileIssue)
+	require.NoError(t, err)
+	props := d.Function.Parameters.Properties
+	assert.Equal(t, []string{"low", "high"}, props["severity"].Enum)
+	assert.Equal(t, []string{"later", "soon", "now"}, props["urgency"].Enum)
+	assert.Equal(t, "string", string(props["urgency"].Type))
+	assert.Equal(t, []string{"light", "dark"}, props["shade"].Enum)
+	assert.Empty(t, props["count"].Enum)
+	assert.Equal(t, "integer", string(props["count"].Type))
+	require.NoError(t, verifyDef(FileIssue, d.Function))
+
+	out, err := CallJSONStr(context.Background(), d, `{"severity": "high", "urgency": "now", "shade": "dark", "count": 2}`)
+	require.NoError(t, err)
+	assert.Equal(t, "high 2 dark 2", out[0])
+
+	_, err = CallJSONStr(context.Background(), d, `{"severity": "high", "urgency": "never", "shade": "dark", "count": 2}`)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
+
+func TestEnumValuesNested(t *testing.T) {
+	d, err := CreateDef(PlanIssue)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"later", "soon", "now"}, d.Function.Parameters.Properties["followup"].Items.Enum)
+
+	out, err := CallJSONStr(context.Background(), d, `{"urgency": "soon", "followup": ["now", "later"]}`)
+	require.NoError(t, err)
+	assert.Equal(t, "1 [now later]", out[0])
+}
+
+func TestRegisterEnumInts(t *testing.T) {
+	type rating int
+	RegisterEnum[rating](1, 3, 5)
+	assert.Equal(t, []string{"1", "3", "5"}, getEnumValuesForCustomType(reflect.TypeOf(rating(0))))
+
+	v, err := decodeArg("3", reflect.TypeOf(rating(0)))
+	require.NoError(t, err)
+	assert.Equal(t, rating(3), v.Interface())
+}
+
+// Wait waits d n times
+func Wait(ctx context.Context, d time.Duration, n uint) string {
+	return fmt.Sprint(d * time.Duration(n))
+}
+
+func TestEnumStdTypes(t *testing.T) {
+	// time.Duration has constants and a String method but is no enum
+	d, err := CreateDef(Wait)
+	require.NoError(t, err)
+	props := d.Function.Parameters.Properties
+	assert.Equal(t, jsonschema.Integer, props["d"].Type)
+	assert.Empty(t, props["d"].Enum)
+	assert.Equal(t, jsonschema.Integer, props["n"].Type)
+
+	_, err = NewTool(Wait, d.Function)
+	require.NoError(t, err)
+	out, err := CallJSONStr(context.Background(), d, `{"d": 1000000000, "n": 2}`)
+	require.NoError(t, err)
+	assert.Equal(t, "2s", out[0])
+
+	assert.Empty(t, collectEnums(nil, reflect.TypeOf(time.Duration(0)), "", map[string]string{}, map[reflect.Type]bool{}))
+}
+
+func TestEnumValuesNotLoadedOnCalls(t *testing.T) {
+	dataType := reflect.TypeOf(jsonschema.DataType(""))
+	// binding and calling tools only uses values known from schemas
+	assert.Empty(t, enumValues(dataType))
+	enums.Lock()
+	_, loaded := enums.packages[dataType.PkgPath()]
+	enums.Unlock()
+	assert.False(t, loaded)
+
+	assert.Contains(t, getEnumValuesForCustomType(dataType), "object")
+	assert.NotEmpty(t, enumValues(dataType))
+	// unlike the main package, other packages do not need a noted directory
+	assert.NotEmpty(t, loadEnumValues(reflect.TypeOf(llm_models.ApprovalAlways)))
+}
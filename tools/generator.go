@@ -9,7 +9,6 @@ import (
 	"os"
 	"path"
 	"reflect"
-	"regexp"
 	"strings"
 )
 
@@ -57,103 +56,20 @@ func CreateStruct(obj interface{}) (*llm_models.Tool, error) {
 	if m != nil {
 		structDesc = m.structComment
 	}
-	def := jsonschema.Definition{
-		Type:       jsonschema.Object,
-		Properties: make(map[string]jsonschema.Definition),
-	}
-
-	var fieldOrder []string
-
-	// Iterate over struct fields
-	var requiredFields []string
-	requiredReg := regexp.MustCompile("-|omitempty|omitzero")
-	for i := 0; i < objType.NumField(); i++ {
-		field := objType.Field(i)
-
-		// Extract field name and type
-		fieldName := field.Name
-		fieldType := field.Type
-
-		// Extract field description from struct tag or comments (if available)
-		fieldDesc := field.Tag.Get("desc")
-		if fieldDesc == "" {
-			fieldDesc = "n/a" // Fallback to field name if no JSON tag is provided
-		}
-		if m != nil {
-			if v, found := m.paramComment[fieldName]; found {
-				fieldDesc = v
-			}
-		}
-		jTag := field.Tag.Get("json")
-
-		if !requiredReg.MatchString(jTag) {
-			requiredFields = append(requiredFields, fieldName)
-		}
-		// Check if the field is a struct and recursively handle it
-		if fieldType.Kind() == reflect.Struct {
-			// Recursively create definition for nested struct
-			nestedDef, err := CreateStruct(reflect.New(fieldType).Interface())
-			if err != nil {
-				return nil, fmt.Errorf("error creating nested struct definition for field %s: %w", fieldName, err)
-			}
-
-			// Add nested struct definition to the properties
-			def.Properties[fieldName] = nestedDef.Function.Parameters
-		} else if fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array {
-			// Check if the array/slice is of structs
-			elemType := fieldType.Elem()
-			if elemType.Kind() == reflect.Struct {
-				// Recursively create definition for nested struct in the array/slice
-				nestedDef, err := CreateStruct(reflect.New(elemType).Interface())
-				if err != nil {
-					return nil, fmt.Errorf("error creating nested struct definition for array/slice element in field %s: %w", fieldName, err)
-				}
-
-				d := nestedDef.Function.Parameters
-				// Add array/slice of structs to the properties
-				def.Properties[fieldName] = jsonschema.Definition{
-					Type:        jsonschema.Array,
-					Items:       &d,
-					Description: fieldDesc,
-				}
-			} else {
-				// Handle array/slice of non-struct types
-				def.Properties[fieldName] = jsonschema.Definition{
-					Type:        mapType(elemType),
-					Description: fieldDesc,
-				}
-			}
-		} else if fieldType.Kind() == reflect.String {
-			// Check if the field is a custom string type (like Role, PromptStreamCommand)
-			if isCustomType(fieldType) {
-				// Retrieve the constants for the custom type (e.g., Role, PromptStreamCommand)
-				enumValues := getEnumValuesForCustomType(fieldType)
-
-				if len(enumValues) > 0 {
-					def.Properties[fieldName] = jsonschema.Definition{
-						Type:        jsonschema.String,
-						Enum:        enumValues,
-						Description: fieldDesc,
-					}
-				}
-			}
-		} else {
-			// Add the field to the definition if it's not a nested struct or array/slice
-			def.Properties[fieldName] = jsonschema.Definition{
-				Type:        mapType(fieldType),
-				Description: fieldDesc,
-			}
-		}
-
-		fieldOrder = append(fieldOrder, fieldName)
+	g := &schemaGen{root: objType}
+	g.findShared(objType)
+	def, fieldOrder, err := g.structSchema(objType, "")
+	if err != nil {
+		return nil, fmt.Errorf("CreateStruct %s: %w", structName, err)
 	}
-	def.Required = requiredFields
+	def.Defs = g.defs
 	return &llm_models.Tool{
 		Function: llm_models.FuncDef{
 			Name:        structName,
 			Description: structDesc,
 			ParamOrder:  fieldOrder,
 			Parameters:  def,
+			Constraints: g.constraints,
 		},
 		CallFunc: nil, // No function associated with a struct
 	}, nil
@@ -166,16 +82,24 @@ func createFuncDef(f interface{}) (*llm_models.Tool, error) {
 	}
 	funcName := getFunctionName(f)
 	funcDesc, data := getFunctionMetadata(f)
+	noteMainDir(f)
+	if structType, ok := structParam(funcType); ok {
+		return createStructArgsDef(f, funcName, funcDesc, structType)
+	}
+	funcDesc, annotations := parseParamAnnotations(funcDesc, data)
+	var defaults map[string]interface{}
 	def := jsonschema.Definition{
 		Type:       jsonschema.Object,
 		Properties: make(map[string]jsonschema.Definition),
 	}
 
 	// Iterate over function parameters
+	g := &schemaGen{}
+	g.findShared(funcParams(funcType)...)
+	var paramOrder []string
 	for i := 0; i < funcType.NumIn(); i++ {
 		paramType := funcType.In(i)
 		paramDescription := ""
-		var paramEnum []string
 
 		// Skip context.Context if present
 		if paramType == reflect.TypeOf((*context.Context)(nil)).Elem() {
@@ -187,23 +111,73 @@ func createFuncDef(f interface{}) (*llm_models.Tool, error) {
 			paramName = data[i]
 		}
 
-		def.Properties[paramName] = jsonschema.Definition{
-			Type:        mapType(paramType),
-			Description: paramDescription,
-			Enum:        paramEnum,
+		a, annotated := annotations[paramName]
+		if a.hasDefault {
+			if defaults == nil {
+				defaults = map[string]interface{}{}
+			}
+			defaults[paramName] = a.value
+			paramDescription = fmt.Sprintf("Defaults to %s.", a.raw)
+		}
+
+		prop, err := g.typeSchema(paramType, paramDescription, paramName)
+		if err != nil {
+			return nil, fmt.Errorf("CreateDef %s: parameter %s: %w", funcName, paramName, err)
+		}
+		def.Properties[paramName] = prop
+		// pointer parameters and parameters annotated as optional may be left out
+		if paramType.Kind() != reflect.Ptr && !annotated {
+			def.Required = append(def.Required, paramName)
 		}
-		def.Required = append(def.Required, paramName)
+		paramOrder = append(paramOrder, paramName)
 	}
+	def.Defs = g.defs
 
 	t := &llm_models.Tool{
 		Function: llm_models.FuncDef{
 			Name:        funcName,
 			Description: funcDesc,
-			ParamOrder:  data,
+			ParamOrder:  paramOrder,
 			Parameters:  def,
+			Defaults:    defaults,
+			Constraints: g.constraints,
 		},
 		CallFunc: f,
 	}
 	return t, nil
 
 }
+
+// createStructArgsDef creates the definition of a function whose only parameter is a struct,
+// the fields of the struct become the parameters of the tool.
+func createStructArgsDef(f interface{}, funcName, funcDesc string, structType reflect.Type) (*llm_models.Tool, error) {
+	s, err := CreateStruct(reflect.New(structType).Interface())
+	if err != nil {
+		return nil, err
+	}
+	if funcDesc == "" {
+		funcDesc = s.Function.Description
+	}
+	// defaults of top level fields are applied when the model leaves them out
+	var defaults map[string]interface{}
+	for _, name := range s.Function.ParamOrder {
+		if c, ok := s.Function.Constraints[name]; ok && c.Default != nil {
+			if defaults == nil {
+				defaults = map[string]interface{}{}
+			}
+			defaults[name] = c.Default
+		}
+	}
+	return &llm_models.Tool{
+		Function: llm_models.FuncDef{
+			Name:        funcName,
+			Description: funcDesc,
+			ParamOrder:  s.Function.ParamOrder,
+			Parameters:  s.Function.Parameters,
+			StructArgs:  true,
+			Defaults:    defaults,
+			Constraints: s.Function.Constraints,
+		},
+		CallFunc: f,
+	}, nil
+}
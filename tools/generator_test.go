@@ -77,8 +77,11 @@ func TestCreateDef(t *testing.T) {
 			output, err := SaveTool("toolsjson/", "", d)
 			require.NoError(t, err)
 			assert.Equal(t, output, "toolsjson/"+d.Function.Name+".json")
-			_, err = NewToolFromFile(tc.Func, output)
+			loaded, err := NewToolFromFile(tc.Func, output)
 			require.NoError(t, err)
+			resp, err = CallTool(context.Background(), loaded, tc.args...)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedOutput, resp)
 		})
 	}
 }
@@ -88,6 +91,16 @@ func PrintTest(ctx context.Context, name string, amount int) string {
 	return fmt.Sprintf("%s %d", name, amount)
 }
 
+// PrintUnsigned prints an amount that cannot be negative
+func PrintUnsigned(ctx context.Context, name string, amount uint) string {
+	return fmt.Sprintf("%s %d", name, amount)
+}
+
+// PrintCounts prints the count stored for name
+func PrintCounts(ctx context.Context, name string, counts map[string]int) string {
+	return fmt.Sprintf("%s %d", name, counts[name])
+}
+
 func T(name string, amount int) string {
 	return fmt.Sprintf("%s %d T", name, amount)
 }
@@ -22,13 +22,22 @@ func GetToolMCP(t *llm_models.Tool) (*mcp.Tool, error) {
 }
 
 // getMCPToolOptions generates a list of MCP tool options based on the provided tool and JSON schema definition.
+// The MCP input schema has no $defs, references are inlined.
 func getMCPToolOptions(t *llm_models.Tool, def jsonschema.Definition) ([]mcp.ToolOption, error) {
 	var options []mcp.ToolOption
+	schema, err := t.Function.JSONSchema()
+	if err != nil {
+		return nil, err
+	}
+	schema = llm_models.InlineRefs(schema)
 	requiredMap := map[string]bool{}
 	for _, r := range def.Required {
 		requiredMap[r] = true
 	}
 	for k, v := range def.Properties {
+		if target, ok := resolveRef(&def, v.Ref); ok {
+			v.Type = target.Type
+		}
 		var option mcp.ToolOption
 		switch v.Type {
 		case jsonschema.String:
@@ -114,8 +123,24 @@ func getMCPToolOptions(t *llm_models.Tool, def jsonschema.Definition) ([]mcp.Too
 			continue
 		}
 
-		options = append(options, option)
+		options = append(options, option, withKeywords(k, llm_models.SchemaNode(schema, k)))
 	}
 
 	return options, nil
 }
+
+// withKeywords copies the keywords of the full property schema, e.g. enum, items, minimum or
+// pattern, into the property created by the typed option before it.
+func withKeywords(name string, keywords map[string]interface{}) mcp.ToolOption {
+	return func(t *mcp.Tool) {
+		prop, ok := t.InputSchema.Properties[name].(map[string]interface{})
+		if !ok {
+			return
+		}
+		// runs after the typed option moved a required flag to the input schema, so the
+		// required list of nested objects is kept
+		for k, v := range keywords {
+			prop[k] = v
+		}
+	}
+}
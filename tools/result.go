@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+)
+
+// ToolResult is the output of a tool prepared for the model.
+type ToolResult struct {
+	Text  string                       // JSON encoded return values, or the output of the tool's ResultFormatter
+	Parts []llm_models.ChatMessagePart // multimodal content (e.g. images) returned by the tool
+}
+
+// FormatResult serializes the values returned by CallTool. The trailing error of the tool
+// function is dropped, llm_models.ChatMessagePart values are returned as Parts and the
+// remaining values are passed to the tool's ResultFormatter or encoded as JSON: a single
+// value on its own (strings as is), several values as an array.
+func FormatResult(t *llm_models.Tool, values []interface{}) (ToolResult, error) {
+	if returnsError(t.CallFunc) && len(values) > 0 {
+		values = values[:len(values)-1]
+	}
+
+	var out ToolResult
+	var rest []interface{}
+	for _, v := range values {
+		switch part := v.(type) {
+		case llm_models.ChatMessagePart:
+			out.Parts = append(out.Parts, part)
+		case *llm_models.ChatMessagePart:
+			if part != nil {
+				out.Parts = append(out.Parts, *part)
+			}
+		case []llm_models.ChatMessagePart:
+			out.Parts = append(out.Parts, part...)
+		default:
+			rest = append(rest, v)
+		}
+	}
+
+	if t.ResultFormatter != nil {
+		text, err := t.ResultFormatter(rest)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		out.Text = text
+		return out, nil
+	}
+
+	var encode interface{} = rest
+	switch len(rest) {
+	case 0:
+		if len(out.Parts) > 0 {
+			return out, nil
+		}
+		encode = nil
+	case 1:
+		if s, ok := rest[0].(string); ok {
+			out.Text = s
+			return out, nil
+		}
+		encode = rest[0]
+	}
+	data, err := json.Marshal(encode)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	out.Text = string(data)
+	return out, nil
+}
+
+// returnsError reports whether the last return value of the function f is an error.
+func returnsError(f interface{}) bool {
+	ft := reflect.TypeOf(f)
+	if ft == nil || ft.Kind() != reflect.Func || ft.NumOut() == 0 {
+		return false
+	}
+	return ft.Out(ft.NumOut() - 1).Implements(reflect.TypeOf((*error)(nil)).Elem())
+}
+
+// ImagePart returns an image part for a tool result from raw image data, e.g. ImagePart("image/png", png).
+func ImagePart(mimeType string, data []byte) llm_models.ChatMessagePart {
+	return ImageURLPart("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data))
+}
+
+// ImageURLPart returns an image part for a tool result referring to an image by URL.
+func ImageURLPart(url string) llm_models.ChatMessagePart {
+	return llm_models.ChatMessagePart{
+		Type:     llm_models.ChatMessagePartTypeImageURL,
+		ImageURL: &llm_models.ChatMessageImageURL{URL: url},
+	}
+}
@@ -0,0 +1,315 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// structField is a field of a struct as seen by encoding/json.
+type structField struct {
+	reflect.StructField
+	name     string       // JSON property name
+	optional bool         // omitempty, omitzero or a pointer
+	owner    reflect.Type // struct declaring the field, differs from the outer struct for embedded fields
+}
+
+// structFields returns the fields encoding/json reads for t, with the fields of embedded
+// structs promoted.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		embedded := field.Type
+		if embedded.Kind() == reflect.Ptr {
+			embedded = embedded.Elem()
+		}
+		if field.Anonymous && name == "" && embedded.Kind() == reflect.Struct {
+			fields = append(fields, structFields(embedded)...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		optional := field.Type.Kind() == reflect.Ptr
+		for _, opt := range strings.Split(opts, ",") {
+			optional = optional || opt == "omitempty" || opt == "omitzero"
+		}
+		fields = append(fields, structField{StructField: field, name: name, optional: optional, owner: t})
+	}
+	return fields
+}
+
+// schemaGen builds JSON schemas from Go types, collecting the keywords jsonschema.Definition
+// cannot express as constraints. Named structs used more than once or containing themselves
+// are generated once under $defs and referenced with $ref, the root struct is referenced as "#".
+type schemaGen struct {
+	constraints map[string]llm_models.SchemaConstraints
+	root        reflect.Type                     // struct the whole schema describes
+	seen        map[reflect.Type]bool            // structs found by findShared
+	shared      map[reflect.Type]bool            // structs emitted under $defs
+	refs        map[reflect.Type]string          // $defs names of the shared structs
+	defs        map[string]jsonschema.Definition // generated $defs
+}
+
+// findShared walks the types reachable from types and marks the named structs that are
+// reached more than once, which covers recursive types.
+func (g *schemaGen) findShared(types ...reflect.Type) {
+	if g.seen == nil {
+		g.seen = map[reflect.Type]bool{}
+		g.shared = map[reflect.Type]bool{}
+	}
+	for _, t := range types {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || t == timeType {
+			continue
+		}
+		if g.seen[t] {
+			g.shared[t] = t.Name() != ""
+			continue
+		}
+		g.seen[t] = true
+		for _, field := range structFields(t) {
+			g.findShared(field.Type)
+		}
+	}
+}
+
+// define generates the $defs entry of a shared struct and returns its name.
+func (g *schemaGen) define(t reflect.Type) (string, error) {
+	if name, ok := g.refs[t]; ok {
+		return name, nil
+	}
+	if g.refs == nil {
+		g.refs = map[reflect.Type]string{}
+		g.defs = map[string]jsonschema.Definition{}
+	}
+	name := defName(t.Name())
+	if _, taken := g.defs[name]; taken {
+		name = defName(path.Base(t.PkgPath()) + "_" + t.Name())
+	}
+	for i := 2; ; i++ {
+		if _, taken := g.defs[name]; !taken {
+			break
+		}
+		name = fmt.Sprintf("%s%d", defName(t.Name()), i)
+	}
+	// reserve the name before generating, the struct may refer to itself
+	g.refs[t] = name
+	g.defs[name] = jsonschema.Definition{}
+	def, _, err := g.structSchema(t, "$defs."+name)
+	g.defs[name] = def
+	return name, err
+}
+
+// defName turns a Go type name, possibly generic, into a $defs key usable in constraint paths.
+func defName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// resolveRef returns the definition ref points to within root, following "#" and "#/$defs/name".
+func resolveRef(root *jsonschema.Definition, ref string) (*jsonschema.Definition, bool) {
+	if ref == "#" {
+		return root, true
+	}
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return nil, false
+	}
+	def, ok := root.Defs[name]
+	return &def, ok
+}
+
+func (g *schemaGen) addConstraints(path string, update func(c *llm_models.SchemaConstraints)) {
+	if g.constraints == nil {
+		g.constraints = map[string]llm_models.SchemaConstraints{}
+	}
+	c := g.constraints[path]
+	update(&c)
+	g.constraints[path] = c
+}
+
+// typeSchema returns the schema of t, path locates it for constraints.
+func (g *schemaGen) typeSchema(t reflect.Type, desc, path string) (jsonschema.Definition, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		g.addConstraints(path, func(c *llm_models.SchemaConstraints) { c.Format = "date-time" })
+		return jsonschema.Definition{Type: jsonschema.String, Description: desc}, nil
+	case t.Kind() == reflect.Struct && t == g.root:
+		return jsonschema.Definition{Ref: "#", Description: desc}, nil
+	case t.Kind() == reflect.Struct && g.shared[t]:
+		name, err := g.define(t)
+		return jsonschema.Definition{Ref: "#/$defs/" + name, Description: desc}, err
+	case t.Kind() == reflect.Struct:
+		def, _, err := g.structSchema(t, path)
+		def.Description = desc
+		return def, err
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		// encoding/json sends []byte as a base64 string
+		return jsonschema.Definition{Type: jsonschema.String, Description: desc}, nil
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		items, err := g.typeSchema(t.Elem(), "", path+"[]")
+		return jsonschema.Definition{Type: jsonschema.Array, Items: &items, Description: desc}, err
+	case t.Kind() == reflect.Map:
+		values, err := g.typeSchema(t.Elem(), "", path+"{}")
+		return jsonschema.Definition{Type: jsonschema.Object, AdditionalProperties: values, Description: desc}, err
+	case t.Kind() == reflect.Interface:
+		// any JSON value
+		return jsonschema.Definition{Description: desc}, nil
+	case isEnumKind(t.Kind()):
+		// custom string and integer types (like Role, PromptStreamCommand) with constants are
+		// enums, listed by name
+		if names := getEnumValuesForCustomType(t); len(names) > 0 {
+			return jsonschema.Definition{Type: jsonschema.String, Enum: names, Description: desc}, nil
+		}
+		if t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64 {
+			g.addConstraints(path, func(c *llm_models.SchemaConstraints) {
+				if c.Minimum == nil {
+					c.Minimum = new(float64)
+				}
+			})
+		}
+	}
+	return jsonschema.Definition{Type: mapType(t), Description: desc}, nil
+}
+
+// structSchema returns the object schema of a struct and its property order.
+func (g *schemaGen) structSchema(t reflect.Type, path string) (jsonschema.Definition, []string, error) {
+	def := jsonschema.Definition{
+		Type:       jsonschema.Object,
+		Properties: make(map[string]jsonschema.Definition),
+	}
+	var order []string
+	metadata := map[reflect.Type]*meta{}
+	for _, field := range structFields(t) {
+		m, ok := metadata[field.owner]
+		if !ok {
+			m = getStructMetadata(field.owner)
+			metadata[field.owner] = m
+		}
+
+		// Extract field description from struct tag or comments (if available)
+		fieldDesc := field.Tag.Get("desc")
+		if fieldDesc == "" {
+			fieldDesc = "n/a"
+		}
+		if m != nil {
+			if v, found := m.paramComment[field.Name]; found {
+				fieldDesc = v
+			}
+		}
+
+		fieldPath := field.name
+		if path != "" {
+			fieldPath = path + "." + field.name
+		}
+		prop, err := g.typeSchema(field.Type, fieldDesc, fieldPath)
+		if err != nil {
+			return def, nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if tag, ok := field.Tag.Lookup("jsonschema"); ok {
+			if err := g.applyTag(&prop, fieldPath, tag); err != nil {
+				return def, nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+		def.Properties[field.name] = prop
+		if !field.optional {
+			def.Required = append(def.Required, field.name)
+		}
+		order = append(order, field.name)
+	}
+	return def, order, nil
+}
+
+// applyTag applies a `jsonschema:"..."` tag: comma separated options like enum=a, enum=b|c,
+// default=5, minimum=1, maximum=10, minLength=1, maxLength=64, pattern=^[a-z]+$, format=email,
+// example=x and deprecated. Values cannot contain commas. The enum of an array applies to its items.
+func (g *schemaGen) applyTag(prop *jsonschema.Definition, path, tag string) error {
+	for _, opt := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		var err error
+		switch key {
+		case "":
+		case "enum":
+			target := prop
+			if prop.Type == jsonschema.Array && prop.Items != nil {
+				target = prop.Items
+			}
+			target.Enum = append(target.Enum, strings.Split(value, "|")...)
+		case "default":
+			g.addConstraints(path, func(c *llm_models.SchemaConstraints) { c.Default = tagValue(value) })
+		case "example":
+			g.addConstraints(path, func(c *llm_models.SchemaConstraints) { c.Examples = append(c.Examples, tagValue(value)) })
+		case "minimum", "maximum":
+			var f float64
+			if f, err = strconv.ParseFloat(value, 64); err == nil {
+				g.addConstraints(path, func(c *llm_models.SchemaConstraints) {
+					if key == "minimum" {
+						c.Minimum = &f
+					} else {
+						c.Maximum = &f
+					}
+				})
+			}
+		case "minLength", "maxLength":
+			var n int
+			if n, err = strconv.Atoi(value); err == nil {
+				g.addConstraints(path, func(c *llm_models.SchemaConstraints) {
+					if key == "minLength" {
+						c.MinLength = &n
+					} else {
+						c.MaxLength = &n
+					}
+				})
+			}
+		case "pattern":
+			g.addConstraints(path, func(c *llm_models.SchemaConstraints) { c.Pattern = value })
+		case "format":
+			g.addConstraints(path, func(c *llm_models.SchemaConstraints) { c.Format = value })
+		case "deprecated":
+			g.addConstraints(path, func(c *llm_models.SchemaConstraints) { c.Deprecated = true })
+		default:
+			return fmt.Errorf("unknown jsonschema tag option %q", key)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid jsonschema tag option %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// tagValue decodes a tag value as JSON, falling back to the raw text.
+func tagValue(value string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return value
+	}
+	return v
+}
@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Audit holds bookkeeping fields shared by several types.
+type Audit struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Ticket is a support ticket.
+type Ticket struct {
+	Audit
+	Title    string            `json:"title" jsonschema:"minLength=3,maxLength=80,example=Printer jammed"`
+	Priority int               `json:"priority,omitempty" jsonschema:"minimum=1,maximum=5,default=3"`
+	Labels   []string          `json:"labels,omitempty" jsonschema:"enum=bug|question"`
+	Email    string            `json:"email" jsonschema:"format=email,pattern=^[^@]+@[^@]+$"`
+	Assignee *string           `json:"assignee"`
+	Extra    map[string]int    `json:"extra,omitempty"`
+	Legacy   string            `json:"legacy,omitempty" jsonschema:"deprecated"`
+	Internal string            `json:"-"`
+	Meta     map[string]string `json:"-"`
+}
+
+func TestCreateStructSchema(t *testing.T) {
+	d, err := CreateDef(Ticket{})
+	require.NoError(t, err)
+	fn := d.Function
+	assert.Equal(t, []string{"created_at", "title", "priority", "labels", "email", "assignee", "extra", "legacy"}, fn.ParamOrder)
+	assert.Equal(t, []string{"created_at", "title", "email"}, fn.Parameters.Required)
+
+	props := fn.Parameters.Properties
+	assert.Equal(t, jsonschema.String, props["created_at"].Type)
+	assert.Equal(t, jsonschema.String, props["assignee"].Type)
+	assert.Equal(t, []string{"bug", "question"}, props["labels"].Items.Enum)
+	assert.Equal(t, jsonschema.Object, props["extra"].Type)
+	assert.Equal(t, jsonschema.Definition{Type: jsonschema.Integer}, props["extra"].AdditionalProperties)
+
+	schema, err := fn.JSONSchema()
+	require.NoError(t, err)
+	data, err := json.Marshal(schema["properties"])
+	require.NoError(t, err)
+	var got map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, "date-time", got["created_at"]["format"])
+	assert.Equal(t, float64(3), got["title"]["minLength"])
+	assert.Equal(t, float64(80), got["title"]["maxLength"])
+	assert.Equal(t, []interface{}{"Printer jammed"}, got["title"]["examples"])
+	assert.Equal(t, float64(1), got["priority"]["minimum"])
+	assert.Equal(t, float64(5), got["priority"]["maximum"])
+	assert.Equal(t, float64(3), got["priority"]["default"])
+	assert.Equal(t, "email", got["email"]["format"])
+	assert.Equal(t, "^[^@]+@[^@]+$", got["email"]["pattern"])
+	assert.Equal(t, true, got["legacy"]["deprecated"])
+
+	mcpTool, err := GetToolMCP(d)
+	require.NoError(t, err)
+	priority := mcpTool.InputSchema.Properties["priority"].(map[string]interface{})
+	assert.Equal(t, float64(5), priority["maximum"])
+	assert.Equal(t, "integer", priority["type"])
+}
+
+type badTag struct {
+	Name string `jsonschema:"minimun=1"`
+}
+
+func TestCreateStructSchemaBadTag(t *testing.T) {
+	_, err := CreateDef(badTag{})
+	assert.ErrorContains(t, err, `field Name: unknown jsonschema tag option "minimun"`)
+}
+
+// OpenTicket opens a support ticket.
+func OpenTicket(ctx context.Context, ticket Ticket) (string, error) {
+	return ticket.Title + " " + ticket.CreatedAt.Format(time.DateOnly), nil
+}
+
+func TestStructArgsJSONNames(t *testing.T) {
+	d, err := CreateDef(OpenTicket)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"priority": float64(3)}, d.Function.Defaults)
+
+	out, err := CallJSONStr(context.Background(), d,
+		`{"created_at": "2024-05-01T10:00:00Z", "title": "Printer jammed", "email": "a@b.c", "assignee": null}`)
+	require.NoError(t, err)
+	assert.Equal(t, "Printer jammed 2024-05-01", out[0])
+}
+
+// TreeNode is a node of a tree.
+type TreeNode struct {
+	Name     string     `json:"name"`
+	Children []TreeNode `json:"children,omitempty"`
+}
+
+// PostalAddress is a postal address.
+type PostalAddress struct {
+	Street string `json:"street"`
+	Zip    string `json:"zip" jsonschema:"pattern=^[0-9]{5}$"`
+}
+
+// Category is a category that can hold sub categories.
+type Category struct {
+	Title string      `json:"title"`
+	Sub   []*Category `json:"sub,omitempty"`
+}
+
+// Shipment ships a parcel.
+type Shipment struct {
+	From       PostalAddress `json:"from"`
+	To         PostalAddress `json:"to"`
+	Categories []Category    `json:"categories,omitempty"`
+}
+
+func TestCreateStructRecursive(t *testing.T) {
+	d, err := CreateDef(TreeNode{})
+	require.NoError(t, err)
+	params := d.Function.Parameters
+	assert.Empty(t, params.Defs)
+	assert.Equal(t, "#", params.Properties["children"].Items.Ref)
+
+	schema, err := d.Function.JSONSchema()
+	require.NoError(t, err)
+	inlined := llm_models.InlineRefs(schema)
+	children := llm_models.SchemaNode(inlined, "children[]")
+	require.NotNil(t, children)
+	assert.Equal(t, "object", children["type"])
+	assert.Equal(t, map[string]interface{}{"type": "object"}, llm_models.SchemaNode(inlined, "children[].children[]"))
+}
+
+func TestCreateStructSharedDefs(t *testing.T) {
+	d, err := CreateDef(Shipment{})
+	require.NoError(t, err)
+	params := d.Function.Parameters
+	assert.Equal(t, "#/$defs/PostalAddress", params.Properties["from"].Ref)
+	assert.Equal(t, "#/$defs/PostalAddress", params.Properties["to"].Ref)
+	assert.Equal(t, "#/$defs/Category", params.Properties["categories"].Items.Ref)
+	require.Contains(t, params.Defs, "PostalAddress")
+	assert.Equal(t, []string{"street", "zip"}, params.Defs["PostalAddress"].Required)
+	assert.Equal(t, "#/$defs/Category", params.Defs["Category"].Properties["sub"].Items.Ref)
+	assert.Equal(t, "^[0-9]{5}$", d.Function.Constraints["$defs.PostalAddress.zip"].Pattern)
+
+	schema, err := d.Function.JSONSchema()
+	require.NoError(t, err)
+	assert.Equal(t, "^[0-9]{5}$", llm_models.SchemaNode(schema, "$defs.PostalAddress.zip")["pattern"])
+
+	// MCP has no $defs, the references are inlined
+	mcpTool, err := GetToolMCP(d)
+	require.NoError(t, err)
+	to := mcpTool.InputSchema.Properties["to"].(map[string]interface{})
+	assert.Equal(t, "object", to["type"])
+	assert.NotContains(t, to, "$ref")
+	zip := to["properties"].(map[string]interface{})["zip"].(map[string]interface{})
+	assert.Equal(t, "^[0-9]{5}$", zip["pattern"])
+
+	// definitions round trip through JSON and are compared on load
+	loaded := *d
+	loaded.Function.Parameters.Defs = map[string]jsonschema.Definition{"PostalAddress": params.Defs["PostalAddress"]}
+	assert.Equal(t, []SchemaDiff{
+		{Path: "parameters.$defs.Category", Field: "definition", Expected: "present", Actual: "missing"},
+	}, DiffDef(d.Function, loaded.Function))
+}
+
+// ShipTo ships a parcel between two addresses.
+func ShipTo(ctx context.Context, from PostalAddress, to PostalAddress) (string, error) {
+	return from.Zip + "->" + to.Zip, nil
+}
+
+func TestCreateFuncSharedDefs(t *testing.T) {
+	d, err := CreateDef(ShipTo)
+	require.NoError(t, err)
+	assert.Equal(t, "#/$defs/PostalAddress", d.Function.Parameters.Properties["from"].Ref)
+	require.NoError(t, verifyDef(ShipTo, d.Function))
+
+	out, err := CallJSONStr(context.Background(), d, `{"from": {"street": "a", "zip": "10115"}, "to": {"street": "b", "zip": "80331"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "10115->80331", out[0])
+}
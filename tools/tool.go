@@ -5,10 +5,17 @@ import (
 	"fmt"
 	"github.com/HiroCloud/llm-client/llm_models"
 	"os"
+	"reflect"
 )
 
+// NewTool binds funcCall to a hand written definition. The definition must describe every
+// parameter of the function, in order, with a compatible JSON type, see ErrInvalidDefinition.
+// Integer enums sent by name must be registered with RegisterEnum, their source is not read.
 func NewTool(funcCall interface{}, def llm_models.FuncDef) (*llm_models.Tool, error) {
-	return nil, nil
+	if err := verifyDef(funcCall, def); err != nil {
+		return nil, err
+	}
+	return &llm_models.Tool{Function: def, CallFunc: funcCall}, nil
 }
 
 func NewToolFromFile(funcCall interface{}, fileName string) (*llm_models.Tool, error) {
@@ -32,25 +39,39 @@ func NewToolFromBytes(funcCall interface{}, data []byte) (*llm_models.Tool, erro
 	if err != nil {
 		return nil, fmt.Errorf("failed ceating tool verification def: %w", err)
 	}
-	if err := verifyTool(d, &loadedTool); err != nil {
+	if err := verifyTool(funcCall, d, &loadedTool); err != nil {
 		return nil, err
 	}
+	loadedTool.CallFunc = d.CallFunc
 	return &loadedTool, nil
 }
 
-func verifyTool(t1, t2 *llm_models.Tool) error {
-	if len(t1.Function.ParamOrder) != len(t2.Function.ParamOrder) {
-		return fmt.Errorf(
-			"invalid function param order, expected %d params, actual %d",
-			len(t1.Function.ParamOrder),
-			len(t2.Function.ParamOrder),
-		)
-	}
-	t1Def := t1.Function.Parameters
-	t2Def := t2.Function.Parameters
-	if t1Def.Type != t2Def.Type {
-		return fmt.Errorf("missmatch type def")
-	}
-	//todo run more verification
-	return nil
+// NewToolFromGenerated binds a definition generated at build time, see Generate, to funcCall.
+// Unlike NewToolFromBytes it does not read the source: the definition is only checked against
+// the signature of the function like in NewTool. Struct definitions are returned as loaded.
+func NewToolFromGenerated(funcCall interface{}, data []byte) (*llm_models.Tool, error) {
+	var loadedTool llm_models.Tool
+	if err := json.Unmarshal(data, &loadedTool); err != nil {
+		return nil, fmt.Errorf("invalid tool format:%w", err)
+	}
+	if reflect.TypeOf(funcCall).Kind() != reflect.Func {
+		return &loadedTool, nil
+	}
+	if err := verifyDef(funcCall, loadedTool.Function); err != nil {
+		return nil, err
+	}
+	loadedTool.CallFunc = funcCall
+	return &loadedTool, nil
+}
+
+// verifyTool checks that the loaded tool t2 still matches t1, created from funcCall, and can call it.
+// Drift is reported as a *DriftError listing every difference.
+func verifyTool(funcCall interface{}, t1, t2 *llm_models.Tool) error {
+	if diffs := DiffDef(t1.Function, t2.Function); len(diffs) > 0 {
+		return &DriftError{Tool: t2.Function.Name, Diffs: diffs}
+	}
+	if t1.CallFunc == nil {
+		return nil // struct definition
+	}
+	return verifyDef(funcCall, t2.Function)
 }
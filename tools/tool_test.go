@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func printTestDef() llm_models.FuncDef {
+	return llm_models.FuncDef{
+		Name:       "print",
+		ParamOrder: []string{"name", "amount"},
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"name":   {Type: jsonschema.String},
+				"amount": {Type: jsonschema.Integer},
+			},
+			Required: []string{"name", "amount"},
+		},
+	}
+}
+
+func TestNewTool(t *testing.T) {
+	type TestCase struct {
+		Name        string
+		Func        interface{}
+		Change      func(d *llm_models.FuncDef)
+		ExpectedErr string
+	}
+
+	tcs := []TestCase{
+		{Name: "Valid", Func: PrintTest},
+		{Name: "Valid without context", Func: T},
+		{Name: "Unsigned", Func: PrintUnsigned},
+		{
+			Name:        "Not a function",
+			Func:        "print",
+			ExpectedErr: "expected a function, got string",
+		},
+		{
+			Name:        "Parameter count",
+			Func:        PrintTest,
+			Change:      func(d *llm_models.FuncDef) { d.ParamOrder = d.ParamOrder[:1] },
+			ExpectedErr: "function has 2 parameters, ParamOrder lists 1",
+		},
+		{
+			Name: "Wrong type",
+			Func: PrintTest,
+			Change: func(d *llm_models.FuncDef) {
+				d.Parameters.Properties["amount"] = jsonschema.Definition{Type: jsonschema.String}
+			},
+			ExpectedErr: `parameter amount: type "string" cannot be decoded into int`,
+		},
+		{
+			Name:        "Unknown property",
+			Func:        PrintTest,
+			Change:      func(d *llm_models.FuncDef) { d.ParamOrder[1] = "count" },
+			ExpectedErr: "parameter count has no property; property amount is not in ParamOrder; required parameter amount is not in ParamOrder",
+		},
+		{
+			Name:   "Optional",
+			Func:   PrintTest,
+			Change: func(d *llm_models.FuncDef) { d.Parameters.Required = []string{"name"} },
+		},
+		{
+			Name:        "Unknown default",
+			Func:        PrintTest,
+			Change:      func(d *llm_models.FuncDef) { d.Defaults = map[string]interface{}{"count": 1} },
+			ExpectedErr: "default for unknown parameter count",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			def := printTestDef()
+			if tc.Change != nil {
+				tc.Change(&def)
+			}
+			tool, err := NewTool(tc.Func, def)
+			if tc.ExpectedErr != "" {
+				assert.ErrorIs(t, err, ErrInvalidDefinition)
+				assert.ErrorContains(t, err, tc.ExpectedErr)
+				return
+			}
+			require.NoError(t, err)
+			out, err := CallJSONStr(context.Background(), tool, `{"name": "a", "amount": 2}`)
+			require.NoError(t, err)
+			assert.Len(t, out, 1)
+		})
+	}
+}
+
+func TestUnsignedSchema(t *testing.T) {
+	d, err := CreateDef(PrintUnsigned)
+	require.NoError(t, err)
+	assert.Equal(t, jsonschema.Integer, d.Function.Parameters.Properties["amount"].Type)
+	schema, err := d.Function.JSONSchema()
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), llm_models.SchemaNode(schema, "amount")["minimum"])
+
+	data, err := json.Marshal(d)
+	require.NoError(t, err)
+	loaded, err := NewToolFromBytes(PrintUnsigned, data)
+	require.NoError(t, err)
+	out, err := CallJSONStr(context.Background(), loaded, `{"name": "a", "amount": 2}`)
+	require.NoError(t, err)
+	assert.Equal(t, "a 2", out[0])
+}
+
+func TestNewToolFromBytesDrift(t *testing.T) {
+	d, err := CreateDef(PrintTest)
+	require.NoError(t, err)
+	d.Function.ParamOrder = []string{"name", "count"}
+	d.Function.Parameters.Properties["name"] = jsonschema.Definition{Type: jsonschema.Integer}
+	d.Function.Parameters.Properties["count"] = d.Function.Parameters.Properties["amount"]
+	delete(d.Function.Parameters.Properties, "amount")
+	d.Function.Parameters.Required = []string{"name", "count"}
+	data, err := json.Marshal(d)
+	require.NoError(t, err)
+
+	_, err = NewToolFromBytes(PrintTest, data)
+	assert.ErrorIs(t, err, ErrInvalidDefinition)
+	var drift *DriftError
+	require.ErrorAs(t, err, &drift)
+	assert.Equal(t, []SchemaDiff{
+		{Path: "param_order", Field: "param_order", Expected: "[name, amount]", Actual: "[name, count]"},
+		{Path: "parameters", Field: "required", Expected: "[name, amount]", Actual: "[name, count]"},
+		{Path: "parameters.amount", Field: "property", Expected: "present", Actual: "missing"},
+		{Path: "parameters.count", Field: "property", Expected: "missing", Actual: "present"},
+		{Path: "parameters.name", Field: "type", Expected: `"string"`, Actual: `"integer"`},
+	}, drift.Diffs)
+}
+
+func TestNewToolFromBytesMapDrift(t *testing.T) {
+	d, err := CreateDef(PrintCounts)
+	require.NoError(t, err)
+	counts := d.Function.Parameters.Properties["counts"]
+	counts.AdditionalProperties = jsonschema.Definition{Type: jsonschema.String}
+	d.Function.Parameters.Properties["counts"] = counts
+	data, err := json.Marshal(d)
+	require.NoError(t, err)
+
+	_, err = NewToolFromBytes(PrintCounts, data)
+	assert.ErrorIs(t, err, ErrInvalidDefinition)
+	var drift *DriftError
+	require.ErrorAs(t, err, &drift)
+	assert.Equal(t, []SchemaDiff{
+		{Path: "parameters.counts{}", Field: "type", Expected: `"integer"`, Actual: `"string"`},
+	}, drift.Diffs)
+
+	d, err = CreateDef(PrintCounts)
+	require.NoError(t, err)
+	data, err = json.Marshal(d)
+	require.NoError(t, err)
+	_, err = NewToolFromBytes(PrintCounts, data)
+	require.NoError(t, err)
+}
+
+func TestDiffDef(t *testing.T) {
+	expected := llm_models.FuncDef{Parameters: jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"address": {Type: jsonschema.Object, Properties: map[string]jsonschema.Definition{
+				"city": {Type: jsonschema.String},
+			}, Required: []string{"city"}},
+			"tags": {Type: jsonschema.Array, Items: &jsonschema.Definition{Type: jsonschema.String, Enum: []string{"a", "b"}}},
+		},
+	}}
+	actual := llm_models.FuncDef{Parameters: jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"address": {Type: jsonschema.Object, Properties: map[string]jsonschema.Definition{
+				"city": {Type: jsonschema.Integer, Description: "descriptions are ignored"},
+			}},
+			"tags": {Type: jsonschema.Array, Items: &jsonschema.Definition{Type: jsonschema.String, Enum: []string{"b", "c"}}},
+		},
+	}}
+
+	assert.Empty(t, DiffDef(expected, expected))
+	assert.Equal(t, []SchemaDiff{
+		{Path: "parameters.address", Field: "required", Expected: "[city]", Actual: "[]"},
+		{Path: "parameters.address.city", Field: "type", Expected: `"string"`, Actual: `"integer"`},
+		{Path: "parameters.tags[]", Field: "enum", Expected: "[a, b]", Actual: "[b, c]"},
+	}, DiffDef(expected, actual))
+}
@@ -0,0 +1,258 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+)
+
+var (
+	// ErrDuplicateTool is returned when a toolbox already holds a tool with the same name.
+	ErrDuplicateTool = errors.New("duplicate tool name")
+	// ErrInvalidToolName is returned for tool names or namespaces providers reject.
+	ErrInvalidToolName = errors.New("invalid tool name")
+	// ErrToolNotFound is returned when no tool matches a name or pattern.
+	ErrToolNotFound = errors.New("tool not found")
+)
+
+// maxToolName is the longest tool name OpenAI and Gemini accept.
+const maxToolName = 64
+
+// toolNameReg matches the names every provider accepts: OpenAI allows letters, digits, "_" and
+// "-", Gemini names must start with a letter or "_".
+var toolNameReg = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// ToolName turns a generated name like "main.GetPerson" into one every provider accepts: the
+// package qualifier is dropped, other characters are replaced by "_" and it is cut to 64 bytes.
+// Legal names are returned as they are.
+func ToolName(name string) string {
+	if toolNameReg.MatchString(name) && len(name) <= maxToolName {
+		return name
+	}
+	name = name[strings.LastIndex(name, ".")+1:]
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+	if name != "" && (('0' <= name[0] && name[0] <= '9') || name[0] == '-') {
+		name = "_" + name
+	}
+	if len(name) > maxToolName {
+		name = name[:maxToolName]
+	}
+	return name
+}
+
+// Toolbox is a registry of tools with unique names every provider accepts. Tools can be added
+// under a namespace, which prefixes their names with "namespace_", and subsets of the tools can
+// be selected per request.
+type Toolbox struct {
+	mu      sync.RWMutex
+	entries []toolboxEntry // in the order tools were added
+	index   map[string]int
+}
+
+type toolboxEntry struct {
+	namespace string
+	tool      llm_models.Tool
+}
+
+// NewToolbox creates an empty toolbox.
+func NewToolbox() *Toolbox {
+	return &Toolbox{index: map[string]int{}}
+}
+
+// Add adds tools under namespace, "" for none. Names are made legal with ToolName and prefixed
+// with the namespace. Nothing is added when a name is taken, see ErrDuplicateTool.
+func (b *Toolbox) Add(namespace string, tools ...llm_models.Tool) error {
+	if namespace != "" && !toolNameReg.MatchString(namespace) {
+		return fmt.Errorf("%w: namespace %q", ErrInvalidToolName, namespace)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	added := map[string]bool{}
+	entries := make([]toolboxEntry, len(tools))
+	for i, t := range tools {
+		name := ToolName(t.Function.Name)
+		if namespace != "" {
+			name = namespace + "_" + name
+		}
+		if !toolNameReg.MatchString(name) || len(name) > maxToolName {
+			return fmt.Errorf("%w: %q", ErrInvalidToolName, name)
+		}
+		if _, taken := b.index[name]; taken || added[name] {
+			return fmt.Errorf("%w: %s", ErrDuplicateTool, name)
+		}
+		added[name] = true
+		t.Function.Name = name
+		entries[i] = toolboxEntry{namespace: namespace, tool: t}
+	}
+	for _, e := range entries {
+		b.index[e.tool.Function.Name] = len(b.entries)
+		b.entries = append(b.entries, e)
+	}
+	return nil
+}
+
+// Register creates the tools of functions and structs with CreateDef and adds them under namespace.
+func (b *Toolbox) Register(namespace string, values ...interface{}) error {
+	tools := make([]llm_models.Tool, len(values))
+	for i, v := range values {
+		t, err := CreateDef(v)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			return fmt.Errorf("register %T: expected a function or a struct", v)
+		}
+		tools[i] = *t
+	}
+	return b.Add(namespace, tools...)
+}
+
+// LoadDir loads the tool JSON files in dir, as written by SaveTool, binds each to the function or
+// struct of values with the same generated name with NewToolFromBytes and adds them under
+// namespace. Every file needs a value and every value a file.
+func (b *Toolbox) LoadDir(namespace, dir string, values ...interface{}) error {
+	byName := map[string]interface{}{}
+	for _, v := range values {
+		byName[generatedName(v)] = v
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var tools []llm_models.Tool
+	for _, f := range files {
+		if f.IsDir() || path.Ext(f.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(path.Join(dir, f.Name()))
+		if err != nil {
+			return err
+		}
+		var def llm_models.Tool
+		if err := json.Unmarshal(data, &def); err != nil {
+			return fmt.Errorf("%s: invalid tool format:%w", f.Name(), err)
+		}
+		v, ok := byName[def.Function.Name]
+		if !ok {
+			return fmt.Errorf("%s: no function or struct for tool %s", f.Name(), def.Function.Name)
+		}
+		delete(byName, def.Function.Name)
+		t, err := NewToolFromBytes(v, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name(), err)
+		}
+		tools = append(tools, *t)
+	}
+	if len(byName) > 0 {
+		missing := make([]string, 0, len(byName))
+		for name := range byName {
+			missing = append(missing, name)
+		}
+		slices.Sort(missing)
+		return fmt.Errorf("%w: no file for %s in %s", ErrToolNotFound, strings.Join(missing, ", "), dir)
+	}
+	return b.Add(namespace, tools...)
+}
+
+// generatedName returns the name CreateDef gives the tool of v.
+func generatedName(v interface{}) string {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Func {
+		return getFunctionName(v)
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// Get returns the tool called name.
+func (b *Toolbox) Get(name string) (llm_models.Tool, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	i, ok := b.index[name]
+	if !ok {
+		return llm_models.Tool{}, false
+	}
+	return b.entries[i].tool, true
+}
+
+// Names returns the names of the tools in the order they were added.
+func (b *Toolbox) Names() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	names := make([]string, len(b.entries))
+	for i, e := range b.entries {
+		names[i] = e.tool.Function.Name
+	}
+	return names
+}
+
+// Tools returns all tools, to pass to a chat like ResolveChatWithTools.
+func (b *Toolbox) Tools() []llm_models.Tool {
+	return b.filter(func(toolboxEntry) bool { return true })
+}
+
+// Namespace returns the tools added under namespace.
+func (b *Toolbox) Namespace(namespace string) []llm_models.Tool {
+	return b.filter(func(e toolboxEntry) bool { return e.namespace == namespace })
+}
+
+// Filter returns the tools keep accepts.
+func (b *Toolbox) Filter(keep func(t llm_models.Tool) bool) []llm_models.Tool {
+	return b.filter(func(e toolboxEntry) bool { return keep(e.tool) })
+}
+
+// Select returns the tools matching one of patterns, names or path.Match patterns like
+// "people_*". A pattern matching no tool is an error, see ErrToolNotFound.
+func (b *Toolbox) Select(patterns ...string) ([]llm_models.Tool, error) {
+	matched := make([]bool, len(patterns))
+	var err error
+	tools := b.filter(func(e toolboxEntry) bool {
+		found := false
+		for i, p := range patterns {
+			ok, matchErr := path.Match(p, e.tool.Function.Name)
+			if matchErr != nil {
+				err = fmt.Errorf("pattern %q: %w", p, matchErr)
+			}
+			matched[i] = matched[i] || ok
+			found = found || ok
+		}
+		return found
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range patterns {
+		if !matched[i] {
+			return nil, fmt.Errorf("%w: %s", ErrToolNotFound, p)
+		}
+	}
+	return tools, nil
+}
+
+func (b *Toolbox) filter(keep func(e toolboxEntry) bool) []llm_models.Tool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var tools []llm_models.Tool
+	for _, e := range b.entries {
+		if keep(e) {
+			tools = append(tools, e.tool)
+		}
+	}
+	return tools
+}
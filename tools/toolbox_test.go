@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolName(t *testing.T) {
+	type TestCase struct {
+		name     string
+		expected string
+	}
+	tests := []TestCase{
+		{name: "GetPerson", expected: "GetPerson"},
+		{name: "get-person_2", expected: "get-person_2"},
+		{name: "main.GetPerson", expected: "GetPerson"},
+		{name: "people.Get Person", expected: "Get_Person"},
+		{name: "pkg.9lives", expected: "_9lives"},
+		{name: "über", expected: "_ber"},
+		{name: "pkg." + strings.Repeat("a", 70), expected: strings.Repeat("a", 64)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ToolName(tt.name))
+		})
+	}
+}
+
+func TestToolbox(t *testing.T) {
+	box := NewToolbox()
+	require.NoError(t, box.Register("", ShipTo, Ticket{}))
+	require.NoError(t, box.Register("issues", FileIssue, PlanIssue))
+	assert.Equal(t, []string{"ShipTo", "Ticket", "issues_FileIssue", "issues_PlanIssue"}, box.Names())
+
+	// names must stay unique, nothing is added on a conflict
+	err := box.Register("", OpenTicket, ShipTo)
+	assert.ErrorIs(t, err, ErrDuplicateTool)
+	assert.Len(t, box.Tools(), 4)
+	assert.ErrorIs(t, box.Register("bad.ns", OpenTicket), ErrInvalidToolName)
+
+	tool, ok := box.Get("issues_FileIssue")
+	require.True(t, ok)
+	out, err := CallJSONStr(context.Background(), &tool, `{"severity": "high", "urgency": "later", "shade": "light", "count": 3}`)
+	require.NoError(t, err)
+	assert.Equal(t, "high 0 light 3", out[0])
+
+	assert.Equal(t, []string{"issues_FileIssue", "issues_PlanIssue"}, toolNames(box.Namespace("issues")))
+	assert.Equal(t, []string{"Ticket"}, toolNames(box.Filter(func(t llm_models.Tool) bool { return t.CallFunc == nil })))
+
+	selected, err := box.Select("issues_*", "ShipTo")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ShipTo", "issues_FileIssue", "issues_PlanIssue"}, toolNames(selected))
+	_, err = box.Select("issues_*", "Missing")
+	assert.ErrorIs(t, err, ErrToolNotFound)
+	_, err = box.Select("[")
+	assert.Error(t, err)
+}
+
+func TestToolboxLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, v := range []interface{}{ShipTo, Ticket{}} {
+		d, err := CreateDef(v)
+		require.NoError(t, err)
+		_, err = SaveTool(dir, "", d)
+		require.NoError(t, err)
+	}
+
+	box := NewToolbox()
+	require.NoError(t, box.LoadDir("shipping", dir, ShipTo, Ticket{}))
+	assert.Equal(t, []string{"shipping_Ticket", "shipping_ShipTo"}, box.Names())
+	tool, ok := box.Get("shipping_ShipTo")
+	require.True(t, ok)
+	out, err := CallJSONStr(context.Background(), &tool, `{"from": {"street": "a", "zip": "1"}, "to": {"street": "b", "zip": "2"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "1->2", out[0])
+
+	err = NewToolbox().LoadDir("", dir, ShipTo)
+	assert.ErrorContains(t, err, "no function or struct for tool Ticket")
+	err = NewToolbox().LoadDir("", dir, ShipTo, Ticket{}, OpenTicket)
+	assert.ErrorIs(t, err, ErrToolNotFound)
+}
+
+func toolNames(tools []llm_models.Tool) []string {
+	var names []string
+	for _, t := range tools {
+		names = append(names, t.Function.Name)
+	}
+	return names
+}
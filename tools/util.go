@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/sashabaranov/go-openai/jsonschema"
 	"go/ast"
@@ -9,10 +10,10 @@ import (
 	"go/token"
 	"log"
 	"os"
-	"path"
 	"reflect"
 	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 )
 
@@ -70,12 +71,50 @@ func getFunctionMetadata(i interface{}) (string, []string) {
 	return doc, paramNames
 }
 
+// paramAnnotation is a "name: optional" line of a function comment.
+type paramAnnotation struct {
+	hasDefault bool
+	raw        string      // default as written in the comment
+	value      interface{} // default decoded as JSON, or the raw text
+}
+
+var paramAnnotationReg = regexp.MustCompile(`^(\w+):\s*optional(?:\s*,?\s*default\s*[:=]?\s*(.+?))?\.?$`)
+
+// parseParamAnnotations removes lines like "name: optional" or "name: optional, default 5" from a
+// function comment and returns the remaining description and the optional parameters. Lines
+// naming something other than one of params are kept in the description.
+func parseParamAnnotations(doc string, params []string) (string, map[string]paramAnnotation) {
+	annotations := map[string]paramAnnotation{}
+	var lines []string
+	for _, line := range strings.Split(doc, "\n") {
+		m := paramAnnotationReg.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil || !slices.Contains(params, m[1]) {
+			lines = append(lines, line)
+			continue
+		}
+		a := paramAnnotation{}
+		if m[2] != "" {
+			a.hasDefault, a.raw, a.value = true, m[2], m[2]
+			var v interface{}
+			if err := json.Unmarshal([]byte(m[2]), &v); err == nil {
+				a.value = v
+			}
+		}
+		annotations[m[1]] = a
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), annotations
+}
+
 // mapType converts Go types to JSON Schema types
 func mapType(t reflect.Type) jsonschema.DataType {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
 	switch t.Kind() {
 	case reflect.String:
 		return jsonschema.String
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		return jsonschema.Integer
 	case reflect.Float32, reflect.Float64:
 		return jsonschema.Number
@@ -90,59 +129,6 @@ func mapType(t reflect.Type) jsonschema.DataType {
 	}
 }
 
-func isCustomType(t reflect.Type) bool {
-	return t.Kind() == reflect.String && t.Name() != "" && t.PkgPath() != ""
-}
-
-// Helper function to retrieve enum values for custom types (Role, PromptStreamCommand, etc.)
-func getEnumValuesForCustomType(t reflect.Type) []string {
-	// Here, we're assuming that you define constants in the same package with the custom type name as a prefix.
-	// For example, `Role` constants would be `RoleUser`, `RoleAdmin`, etc.
-
-	var constants []string
-	//packagePath := t.PkgPath()
-	// Get the package path of the type.
-	packagePath := t.PkgPath()
-	if packagePath == "" {
-		return nil
-	}
-
-	//path, err := os.Executable()
-	//if err != nil {
-	//	log.Println(err)
-	//}
-
-	cwd, err := os.Getwd()
-	if err != nil {
-		return nil
-	}
-	dir, err := os.ReadDir(joinPaths(cwd, packagePath))
-	if err != nil {
-		return nil
-	}
-	for _, d := range dir {
-		f := path.Join(joinPaths(cwd, packagePath), d.Name())
-
-		data, err := os.ReadFile(f)
-		if err != nil {
-			return nil
-		}
-
-		lines := strings.Split(string(data), "\n")
-		enumPattern := fmt.Sprintf(`\s+%s([A-Za-z0-9_]+)\s*=\s*%s\(["']([A-Za-z0-9_]+)["']\)`, t.Name(), t.Name())
-		m := regexp.MustCompile(enumPattern)
-		for _, line := range lines {
-			matches := m.FindStringSubmatch(line)
-			if len(matches) > 2 {
-				// The second capture group will contain the value of the enum (e.g., "user", "assistant")
-				constants = append(constants, matches[2])
-			}
-		}
-
-	}
-	return constants
-}
-
 // meta is metadata for a struct
 // structComment is the comments above a struct to define what it stores/does
 // paramComment stores each of the struct parameter comments
@@ -338,23 +324,3 @@ func getStructMetadata(t reflect.Type) *meta {
 	}
 	return m
 }
-
-// joinPaths joins two paths, ensuring no duplication of base path prefixes.
-func joinPaths(basePath, subPath string) string {
-	// Ensure basePath doesn't contain the prefix of subPath to avoid duplication
-	bps := strings.Split(basePath, "/")
-	sps := strings.Split(subPath, "/")
-	var ps []string
-	for _, p := range bps {
-		if p == sps[0] {
-			for _, p := range sps {
-				ps = append(ps, p)
-			}
-			return strings.Join(ps, "/")
-		} else {
-			ps = append(ps, p)
-		}
-	}
-	// Join the paths
-	return ""
-}
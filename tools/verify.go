@@ -0,0 +1,358 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// ErrInvalidDefinition is returned when a function definition does not match the Go function it is bound to.
+var ErrInvalidDefinition = errors.New("invalid tool definition")
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// funcParams returns the parameters of fnType the model has to provide, without a leading context.Context.
+func funcParams(fnType reflect.Type) []reflect.Type {
+	var params []reflect.Type
+	for i := 0; i < fnType.NumIn(); i++ {
+		if i == 0 && fnType.In(i) == contextType {
+			continue
+		}
+		params = append(params, fnType.In(i))
+	}
+	return params
+}
+
+// structParam returns the struct type of a function whose only parameter, besides a leading
+// context.Context, is a struct or a pointer to one. time.Time is treated as a value.
+func structParam(fnType reflect.Type) (reflect.Type, bool) {
+	params := funcParams(fnType)
+	if len(params) != 1 {
+		return nil, false
+	}
+	t := params[0]
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return nil, false
+	}
+	return t, true
+}
+
+// defParams returns the Go types of the parameters def describes, in order. With StructArgs
+// these are the exported fields of the function's struct parameter.
+func defParams(fnType reflect.Type, def llm_models.FuncDef) ([]reflect.Type, error) {
+	if !def.StructArgs {
+		return funcParams(fnType), nil
+	}
+	structType, ok := structParam(fnType)
+	if !ok {
+		return nil, fmt.Errorf("struct arguments need a function taking a single struct, got %s", fnType)
+	}
+	var fields []reflect.Type
+	for _, field := range structFields(structType) {
+		fields = append(fields, field.Type)
+	}
+	return fields, nil
+}
+
+// verifyDef checks that def can be used to call funcCall: one ParamOrder entry per parameter
+// and a property with a compatible JSON type for each of them. Every mismatch is reported.
+func verifyDef(funcCall interface{}, def llm_models.FuncDef) error {
+	if funcCall == nil {
+		return fmt.Errorf("%w: %s: no function", ErrInvalidDefinition, def.Name)
+	}
+	fnType := reflect.TypeOf(funcCall)
+	if fnType.Kind() != reflect.Func {
+		return fmt.Errorf("%w: %s: expected a function, got %s", ErrInvalidDefinition, def.Name, fnType)
+	}
+	params, err := defParams(fnType, def)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidDefinition, def.Name, err)
+	}
+
+	var problems []string
+	if len(params) > 0 && def.Parameters.Type != jsonschema.Object {
+		problems = append(problems, fmt.Sprintf("parameters must be of type object, got %q", def.Parameters.Type))
+	}
+	if len(def.ParamOrder) != len(params) {
+		problems = append(problems, fmt.Sprintf("function has %d parameters, ParamOrder lists %d", len(params), len(def.ParamOrder)))
+	}
+	for i, name := range def.ParamOrder {
+		if slices.Index(def.ParamOrder, name) != i {
+			problems = append(problems, fmt.Sprintf("parameter %s is listed twice in ParamOrder", name))
+			continue
+		}
+		prop, ok := def.Parameters.Properties[name]
+		if target, isRef := resolveRef(&def.Parameters, prop.Ref); isRef {
+			prop = *target
+		}
+		if !ok {
+			problems = append(problems, fmt.Sprintf("parameter %s has no property", name))
+		} else if i < len(params) && !compatibleType(params[i], prop.Type) {
+			problems = append(problems, fmt.Sprintf("parameter %s: type %q cannot be decoded into %s", name, prop.Type, params[i]))
+		}
+	}
+	for name := range def.Parameters.Properties {
+		if !slices.Contains(def.ParamOrder, name) {
+			problems = append(problems, fmt.Sprintf("property %s is not in ParamOrder", name))
+		}
+	}
+	for name := range def.Defaults {
+		if !slices.Contains(def.ParamOrder, name) {
+			problems = append(problems, fmt.Sprintf("default for unknown parameter %s", name))
+		}
+	}
+	for _, name := range def.Parameters.Required {
+		if !slices.Contains(def.ParamOrder, name) {
+			problems = append(problems, fmt.Sprintf("required parameter %s is not in ParamOrder", name))
+		}
+	}
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return fmt.Errorf("%w: %s: %s", ErrInvalidDefinition, def.Name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// compatibleType reports whether a JSON value of schema type dt can be passed as t.
+// An empty type accepts anything.
+func compatibleType(t reflect.Type, dt jsonschema.DataType) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if dt == "" || t.Kind() == reflect.Interface {
+		return true
+	}
+	if t == timeType || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8) {
+		return dt == jsonschema.String
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return dt == jsonschema.String
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// enums are sent by name
+		return dt == jsonschema.Integer || (dt == jsonschema.String && len(enumValues(t)) > 0)
+	case reflect.Float32, reflect.Float64:
+		return dt == jsonschema.Number || dt == jsonschema.Integer
+	case reflect.Bool:
+		return dt == jsonschema.Boolean
+	case reflect.Slice, reflect.Array:
+		return dt == jsonschema.Array
+	case reflect.Map, reflect.Struct:
+		return dt == jsonschema.Object
+	}
+	return false
+}
+
+// SchemaDiff is a single difference between two function definitions.
+type SchemaDiff struct {
+	Path     string // location of the difference, e.g. "parameters.address.city" or "parameters.tags[]"
+	Field    string // compared field: param_order, struct_args, constraints, default, type, $ref, property, definition, required, enum or items
+	Expected string // value in the definition generated from the Go function
+	Actual   string // value in the loaded definition
+}
+
+func (d SchemaDiff) String() string {
+	return fmt.Sprintf("%s: %s expected %s, actual %s", d.Path, d.Field, d.Expected, d.Actual)
+}
+
+// DriftError is returned when a loaded definition no longer matches its Go function.
+// It wraps ErrInvalidDefinition.
+type DriftError struct {
+	Tool  string
+	Diffs []SchemaDiff
+}
+
+func (e *DriftError) Error() string {
+	lines := make([]string, len(e.Diffs))
+	for i, d := range e.Diffs {
+		lines[i] = d.String()
+	}
+	return fmt.Sprintf("%s: %s does not match its function: %s", ErrInvalidDefinition, e.Tool, strings.Join(lines, "; "))
+}
+
+func (e *DriftError) Unwrap() error {
+	return ErrInvalidDefinition
+}
+
+// DiffDef compares the parameters of two definitions recursively: parameter order, constraints,
+// defaults, property names, types, references, $defs, enums, required lists and array items.
+// Descriptions are ignored.
+func DiffDef(expected, actual llm_models.FuncDef) []SchemaDiff {
+	var diffs []SchemaDiff
+	if !slices.Equal(expected.ParamOrder, actual.ParamOrder) {
+		diffs = append(diffs, SchemaDiff{
+			Path:     "param_order",
+			Field:    "param_order",
+			Expected: listString(expected.ParamOrder),
+			Actual:   listString(actual.ParamOrder),
+		})
+	}
+	if expected.StructArgs != actual.StructArgs {
+		diffs = append(diffs, SchemaDiff{
+			Path:     "struct_args",
+			Field:    "struct_args",
+			Expected: fmt.Sprint(expected.StructArgs),
+			Actual:   fmt.Sprint(actual.StructArgs),
+		})
+	}
+	for _, path := range unionKeys(expected.Constraints, actual.Constraints) {
+		e, a := formatConstraints(expected.Constraints, path), formatConstraints(actual.Constraints, path)
+		if e != a {
+			diffs = append(diffs, SchemaDiff{Path: "constraints." + path, Field: "constraints", Expected: e, Actual: a})
+		}
+	}
+	for _, name := range unionKeys(expected.Defaults, actual.Defaults) {
+		e, a := formatDefault(expected.Defaults, name), formatDefault(actual.Defaults, name)
+		if e != a {
+			diffs = append(diffs, SchemaDiff{Path: "defaults." + name, Field: "default", Expected: e, Actual: a})
+		}
+	}
+	return diffSchema(diffs, "parameters", &expected.Parameters, &actual.Parameters)
+}
+
+func formatConstraints(constraints map[string]llm_models.SchemaConstraints, path string) string {
+	c, ok := constraints[path]
+	if !ok {
+		return "none"
+	}
+	data, _ := json.Marshal(c)
+	return string(data)
+}
+
+func formatDefault(defaults map[string]interface{}, name string) string {
+	v, ok := defaults[name]
+	if !ok {
+		return "none"
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func diffSchema(diffs []SchemaDiff, path string, expected, actual *jsonschema.Definition) []SchemaDiff {
+	if expected.Type != actual.Type {
+		diffs = append(diffs, SchemaDiff{Path: path, Field: "type", Expected: quote(string(expected.Type)), Actual: quote(string(actual.Type))})
+	}
+	if expected.Ref != actual.Ref {
+		diffs = append(diffs, SchemaDiff{Path: path, Field: "$ref", Expected: quote(expected.Ref), Actual: quote(actual.Ref)})
+	}
+	if !sameSet(expected.Enum, actual.Enum) {
+		diffs = append(diffs, SchemaDiff{Path: path, Field: "enum", Expected: listString(expected.Enum), Actual: listString(actual.Enum)})
+	}
+	if !sameSet(expected.Required, actual.Required) {
+		diffs = append(diffs, SchemaDiff{Path: path, Field: "required", Expected: listString(expected.Required), Actual: listString(actual.Required)})
+	}
+
+	for _, name := range unionKeys(expected.Properties, actual.Properties) {
+		e, inExpected := expected.Properties[name]
+		a, inActual := actual.Properties[name]
+		switch {
+		case !inActual:
+			diffs = append(diffs, SchemaDiff{Path: path + "." + name, Field: "property", Expected: "present", Actual: "missing"})
+		case !inExpected:
+			diffs = append(diffs, SchemaDiff{Path: path + "." + name, Field: "property", Expected: "missing", Actual: "present"})
+		default:
+			diffs = diffSchema(diffs, path+"."+name, &e, &a)
+		}
+	}
+
+	for _, name := range unionKeys(expected.Defs, actual.Defs) {
+		e, inExpected := expected.Defs[name]
+		a, inActual := actual.Defs[name]
+		switch {
+		case !inActual:
+			diffs = append(diffs, SchemaDiff{Path: path + ".$defs." + name, Field: "definition", Expected: "present", Actual: "missing"})
+		case !inExpected:
+			diffs = append(diffs, SchemaDiff{Path: path + ".$defs." + name, Field: "definition", Expected: "missing", Actual: "present"})
+		default:
+			diffs = diffSchema(diffs, path+".$defs."+name, &e, &a)
+		}
+	}
+
+	switch {
+	case expected.Items != nil && actual.Items != nil:
+		diffs = diffSchema(diffs, path+"[]", expected.Items, actual.Items)
+	case expected.Items != nil:
+		diffs = append(diffs, SchemaDiff{Path: path + "[]", Field: "items", Expected: "present", Actual: "missing"})
+	case actual.Items != nil:
+		diffs = append(diffs, SchemaDiff{Path: path + "[]", Field: "items", Expected: "missing", Actual: "present"})
+	}
+
+	e, a := additionalSchema(expected.AdditionalProperties), additionalSchema(actual.AdditionalProperties)
+	switch {
+	case e != nil && a != nil:
+		diffs = diffSchema(diffs, path+"{}", e, a)
+	case e != nil:
+		diffs = append(diffs, SchemaDiff{Path: path + "{}", Field: "additionalProperties", Expected: "present", Actual: "missing"})
+	case a != nil:
+		diffs = append(diffs, SchemaDiff{Path: path + "{}", Field: "additionalProperties", Expected: "missing", Actual: "present"})
+	}
+	return diffs
+}
+
+// additionalSchema returns the value schema of a map from additionalProperties, which holds a
+// Definition when generated and a decoded JSON object when loaded from a file. Anything else
+// (unset or a boolean) gives nil.
+func additionalSchema(v any) *jsonschema.Definition {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case jsonschema.Definition:
+		return &v
+	case *jsonschema.Definition:
+		return v
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var def jsonschema.Definition
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil
+	}
+	return &def
+}
+
+// unionKeys returns the keys of a and b, sorted.
+func unionKeys[V any](a, b map[string]V) []string {
+	names := make([]string, 0, len(a)+len(b))
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// sameSet reports whether a and b hold the same values in any order.
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+func listString(values []string) string {
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+func quote(s string) string {
+	return fmt.Sprintf("%q", s)
+}
@@ -0,0 +1,75 @@
+package vectorstore
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Chunk splits text into pieces of at most size runes where consecutive pieces share
+// at least overlap runes (rounded to whole words). Pieces are cut at whitespace when possible so words stay intact.
+func Chunk(text string, size, overlap int) []string {
+	if size <= 0 {
+		return nil
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+	runes := []rune(strings.TrimSpace(text))
+	var chunks []string
+	for start := 0; start < len(runes); {
+		end := min(start+size, len(runes))
+		if end < len(runes) {
+			// move the cut back to the last whitespace, unless that would leave almost nothing
+			for cut := end; cut > start+size/2; cut-- {
+				if unicode.IsSpace(runes[cut]) {
+					end = cut
+					break
+				}
+			}
+		}
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+
+		next := end
+		if overlap > 0 {
+			next = end - overlap
+			// start the overlap at a word boundary as well
+			for next > start && !unicode.IsSpace(runes[next-1]) && !unicode.IsSpace(runes[next]) {
+				next--
+			}
+		}
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return chunks
+}
+
+// ChunkFile reads fileName and splits it into Documents with IDs "<file>#<n>".
+// Every document gets the "source" metadata key plus the given metadata.
+func ChunkFile(fileName string, size, overlap int, metadata map[string]string) ([]Document, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var docs []Document
+	for n, chunk := range Chunk(string(data), size, overlap) {
+		md := map[string]string{"source": fileName}
+		for k, v := range metadata {
+			md[k] = v
+		}
+		docs = append(docs, Document{
+			ID:       fmt.Sprintf("%s#%d", fileName, n),
+			Text:     chunk,
+			Metadata: md,
+		})
+	}
+	return docs, nil
+}
@@ -0,0 +1,233 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Metric selects how query and document vectors are compared, higher scores are better.
+type Metric string
+
+const (
+	MetricCosine     Metric = "cosine"
+	MetricDotProduct Metric = "dot_product"
+)
+
+// Document is a stored chunk of text with its embedding and free form metadata.
+type Document struct {
+	ID       string            `json:"id"`
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Vector   []float32         `json:"vector"`
+}
+
+// Result is a search hit.
+type Result struct {
+	Document
+	Score float32 `json:"score"`
+}
+
+// Filter decides whether a document with the given metadata may be returned by a search.
+type Filter func(metadata map[string]string) bool
+
+// MatchMetadata returns a Filter that only accepts documents containing every key/value pair of want.
+func MatchMetadata(want map[string]string) Filter {
+	return func(metadata map[string]string) bool {
+		for k, v := range want {
+			if metadata[k] != v {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Index is a brute force in-memory vector index, safe for concurrent use.
+type Index struct {
+	mu     sync.RWMutex
+	metric Metric
+	dims   int
+	docs   []Document
+	byID   map[string]int
+}
+
+// indexFile is the on-disk format written by Save.
+type indexFile struct {
+	Metric    Metric     `json:"metric"`
+	Dims      int        `json:"dims"`
+	Documents []Document `json:"documents"`
+}
+
+// NewIndex creates an empty index using metric (cosine if empty).
+func NewIndex(metric Metric) *Index {
+	if metric == "" {
+		metric = MetricCosine
+	}
+	return &Index{metric: metric, byID: map[string]int{}}
+}
+
+// Len returns the number of stored documents.
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.docs)
+}
+
+// Add inserts docs, replacing existing documents with the same ID.
+// All vectors of an index must have the same dimension. Nothing is added when a document is invalid.
+func (i *Index) Add(docs ...Document) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	dims := i.dims
+	for _, d := range docs {
+		if d.ID == "" {
+			return errors.New("document without id")
+		}
+		if len(d.Vector) == 0 {
+			return fmt.Errorf("document %s has no vector", d.ID)
+		}
+		if dims == 0 {
+			dims = len(d.Vector)
+		} else if len(d.Vector) != dims {
+			return fmt.Errorf("document %s: expected %d dimensions, got %d", d.ID, dims, len(d.Vector))
+		}
+	}
+	i.dims = dims
+	for _, d := range docs {
+		if pos, ok := i.byID[d.ID]; ok {
+			i.docs[pos] = d
+			continue
+		}
+		i.byID[d.ID] = len(i.docs)
+		i.docs = append(i.docs, d)
+	}
+	return nil
+}
+
+// Delete removes the documents with the given IDs, unknown IDs are ignored.
+func (i *Index) Delete(ids ...string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, id := range ids {
+		pos, ok := i.byID[id]
+		if !ok {
+			continue
+		}
+		last := len(i.docs) - 1
+		i.docs[pos] = i.docs[last]
+		i.byID[i.docs[pos].ID] = pos
+		i.docs = i.docs[:last]
+		delete(i.byID, id)
+	}
+}
+
+// Get returns the document with the given ID.
+func (i *Index) Get(id string) (Document, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	pos, ok := i.byID[id]
+	if !ok {
+		return Document{}, false
+	}
+	return i.docs[pos], true
+}
+
+// Search returns the k best matching documents accepted by filter (nil accepts all), best first.
+func (i *Index) Search(query []float32, k int, filter Filter) ([]Result, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if len(i.docs) == 0 {
+		return nil, nil
+	}
+	if len(query) != i.dims {
+		return nil, fmt.Errorf("query has %d dimensions, index has %d", len(query), i.dims)
+	}
+
+	var results []Result
+	for _, d := range i.docs {
+		if filter != nil && !filter(d.Metadata) {
+			continue
+		}
+		results = append(results, Result{Document: d, Score: score(i.metric, query, d.Vector)})
+	}
+	sort.SliceStable(results, func(a, b int) bool {
+		return results[a].Score > results[b].Score
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// Save writes the index as JSON to fileName, creating parent directories if needed.
+func (i *Index) Save(fileName string) error {
+	i.mu.RLock()
+	data, err := json.Marshal(indexFile{Metric: i.metric, Dims: i.dims, Documents: i.docs})
+	i.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	// write to a temporary file first so a crash never leaves a truncated index behind, each
+	// save uses its own so concurrent saves do not write into each other's
+	f, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, fileName)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Load reads an index previously written by Save.
+func Load(fileName string) (*Index, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var f indexFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid index file %s: %w", fileName, err)
+	}
+	idx := NewIndex(f.Metric)
+	if err := idx.Add(f.Documents...); err != nil {
+		return nil, fmt.Errorf("invalid index file %s: %w", fileName, err)
+	}
+	return idx, nil
+}
+
+func score(metric Metric, a, b []float32) float32 {
+	var dot, normA, normB float64
+	for n := range a {
+		dot += float64(a[n]) * float64(b[n])
+		normA += float64(a[n]) * float64(a[n])
+		normB += float64(b[n]) * float64(b[n])
+	}
+	if metric == MetricDotProduct {
+		return float32(dot)
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
@@ -0,0 +1,137 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	llm_client "github.com/HiroCloud/llm-client"
+	"github.com/HiroCloud/llm-client/llm_models"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Retriever embeds documents and queries with an Embedder and searches them in an Index.
+type Retriever struct {
+	Index    *Index
+	Embedder llm_client.Embedder
+	Model    string // Embedding model (provider default if empty)
+	TopK     int    // Number of results per search (defaults to 5)
+	Filter   Filter // Optional filter applied to every search
+}
+
+// Ingest embeds the documents that have no vector yet and adds all of them to the index.
+func (r *Retriever) Ingest(ctx context.Context, docs ...Document) error {
+	var texts []string
+	var missing []int
+	for n, d := range docs {
+		if len(d.Vector) == 0 {
+			texts = append(texts, d.Text)
+			missing = append(missing, n)
+		}
+	}
+	if len(texts) > 0 {
+		resp, err := r.Embedder.Embed(ctx, llm_client.EmbeddingRequest{
+			Model:    r.Model,
+			Input:    texts,
+			TaskType: llm_client.EmbeddingTaskRetrievalDocument,
+		})
+		if err != nil {
+			return fmt.Errorf("embed documents: %w", err)
+		}
+		if len(resp.Embeddings) != len(texts) {
+			return fmt.Errorf("embed documents: expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+		}
+		// the caller's slice is left alone
+		docs = slices.Clone(docs)
+		for n, pos := range missing {
+			docs[pos].Vector = resp.Embeddings[n]
+		}
+	}
+	return r.Index.Add(docs...)
+}
+
+// IngestFile chunks fileName (see ChunkFile) and ingests the chunks.
+func (r *Retriever) IngestFile(ctx context.Context, fileName string, size, overlap int, metadata map[string]string) error {
+	docs, err := ChunkFile(fileName, size, overlap, metadata)
+	if err != nil {
+		return err
+	}
+	return r.Ingest(ctx, docs...)
+}
+
+// Search embeds query and returns the best matching documents.
+func (r *Retriever) Search(ctx context.Context, query string) ([]Result, error) {
+	if query == "" {
+		return nil, errors.New("empty query")
+	}
+	resp, err := r.Embedder.Embed(ctx, llm_client.EmbeddingRequest{
+		Model:    r.Model,
+		Input:    []string{query},
+		TaskType: llm_client.EmbeddingTaskRetrievalQuery,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	if len(resp.Embeddings) == 0 {
+		return nil, errors.New("embed query: no embedding returned")
+	}
+	k := r.TopK
+	if k <= 0 {
+		k = 5
+	}
+	return r.Index.Search(resp.Embeddings[0], k, r.Filter)
+}
+
+// toolResult is what the model sees for each search hit.
+type toolResult struct {
+	ID       string            `json:"id"`
+	Score    float32           `json:"score"`
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Tool exposes the retriever as a tool taking a single "query" parameter so agents run by
+// ResolveChatWithTools can search the documents. The tool returns the hits as JSON.
+func (r *Retriever) Tool(name, description string) *llm_models.Tool {
+	if name == "" {
+		name = "search_documents"
+	}
+	if description == "" {
+		description = "Search the document store and return the most relevant passages for a query"
+	}
+	search := func(ctx context.Context, query string) (string, error) {
+		results, err := r.Search(ctx, query)
+		if err != nil {
+			return "", err
+		}
+		out := make([]toolResult, len(results))
+		for n, res := range results {
+			out[n] = toolResult{ID: res.ID, Score: res.Score, Text: res.Text, Metadata: res.Metadata}
+		}
+		data, err := json.Marshal(out)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return &llm_models.Tool{
+		Function: llm_models.FuncDef{
+			Name:        name,
+			Description: description,
+			ParamOrder:  []string{"query"},
+			Parameters: jsonschema.Definition{
+				Type: jsonschema.Object,
+				Properties: map[string]jsonschema.Definition{
+					"query": {
+						Type:        jsonschema.String,
+						Description: "natural language search query",
+					},
+				},
+				Required: []string{"query"},
+			},
+		},
+		CallFunc: search,
+	}
+}
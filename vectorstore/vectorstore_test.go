@@ -0,0 +1,170 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	llm_client "github.com/HiroCloud/llm-client"
+	"github.com/HiroCloud/llm-client/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// letterEmbedder embeds texts as counts of the letters a-e, good enough to rank by topic.
+type letterEmbedder struct{}
+
+func (letterEmbedder) Embed(ctx context.Context, req llm_client.EmbeddingRequest) (llm_client.EmbeddingResponse, error) {
+	var out llm_client.EmbeddingResponse
+	for _, text := range req.Input {
+		v := make([]float32, 5)
+		for _, r := range strings.ToLower(text) {
+			if r >= 'a' && r <= 'e' {
+				v[r-'a']++
+			}
+		}
+		out.Embeddings = append(out.Embeddings, v)
+	}
+	return out, nil
+}
+
+func TestIndexSearch(t *testing.T) {
+	idx := NewIndex(MetricCosine)
+	require.NoError(t, idx.Add(
+		Document{ID: "x", Vector: []float32{1, 0}, Metadata: map[string]string{"lang": "en"}},
+		Document{ID: "y", Vector: []float32{0, 1}, Metadata: map[string]string{"lang": "de"}},
+		Document{ID: "z", Vector: []float32{1, 1}, Metadata: map[string]string{"lang": "en"}},
+	))
+	assert.Error(t, idx.Add(Document{ID: "bad", Vector: []float32{1, 2, 3}}))
+	// a batch with an invalid document adds nothing
+	assert.Error(t, idx.Add(Document{ID: "w", Vector: []float32{1, 1}}, Document{ID: "bad", Vector: []float32{1}}))
+	_, ok := idx.Get("w")
+	assert.False(t, ok)
+
+	results, err := idx.Search([]float32{1, 0.1}, 2, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "x", results[0].ID)
+	assert.Equal(t, "z", results[1].ID)
+
+	results, err = idx.Search([]float32{0, 1}, 0, MatchMetadata(map[string]string{"lang": "en"}))
+	require.NoError(t, err)
+	assert.Equal(t, "z", results[0].ID)
+	assert.Len(t, results, 2)
+
+	idx.Delete("x")
+	assert.Equal(t, 2, idx.Len())
+	_, ok = idx.Get("x")
+	assert.False(t, ok)
+	d, ok := idx.Get("z")
+	assert.True(t, ok)
+	assert.Equal(t, []float32{1, 1}, d.Vector)
+
+	dot := NewIndex(MetricDotProduct)
+	require.NoError(t, dot.Add(Document{ID: "short", Vector: []float32{1, 0}}, Document{ID: "long", Vector: []float32{3, 3}}))
+	results, err = dot.Search([]float32{1, 0}, 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "long", results[0].ID)
+}
+
+func TestIndexSaveLoad(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "store", "index.json")
+	idx := NewIndex(MetricDotProduct)
+	require.NoError(t, idx.Add(Document{ID: "a", Text: "hello", Vector: []float32{1, 2}}))
+	require.NoError(t, idx.Save(fileName))
+
+	loaded, err := Load(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, MetricDotProduct, loaded.metric)
+	d, ok := loaded.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, "hello", d.Text)
+}
+
+func TestIndexSaveConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "index.json")
+	idx := NewIndex(MetricDotProduct)
+	require.NoError(t, idx.Add(Document{ID: "a", Vector: []float32{1, 2}}))
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for n := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[n] = idx.Save(fileName)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	loaded, err := Load(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, 1, loaded.Len())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files are left behind")
+}
+
+func TestChunk(t *testing.T) {
+	chunks := Chunk("one two three four five six seven", 10, 4)
+	assert.Equal(t, []string{"one two", "two three", "three four", "four five", "five six", "six seven"}, chunks)
+	for _, c := range chunks {
+		assert.LessOrEqual(t, len(c), 10)
+	}
+	assert.Equal(t, []string{"short"}, Chunk(" short ", 100, 10))
+	assert.Nil(t, Chunk("text", 0, 0))
+}
+
+func TestRetrieverTool(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "notes.txt")
+	require.NoError(t, os.WriteFile(fileName, []byte("aaaa aaaa\nbbbb bbbb\ncccc cccc"), 0644))
+
+	r := &Retriever{Index: NewIndex(""), Embedder: letterEmbedder{}, TopK: 1}
+	require.NoError(t, r.IngestFile(context.Background(), fileName, 10, 0, map[string]string{"kind": "note"}))
+	assert.Equal(t, 3, r.Index.Len())
+
+	tool := r.Tool("", "")
+	assert.Equal(t, "search_documents", tool.Function.Name)
+	out, err := tools.CallJSONStr(context.Background(), tool, `{"query":"bb"}`)
+	require.NoError(t, err)
+
+	var hits []toolResult
+	require.NoError(t, json.Unmarshal([]byte(out[0].(string)), &hits))
+	require.Len(t, hits, 1)
+	assert.Equal(t, "bbbb bbbb", hits[0].Text)
+	assert.Equal(t, "note", hits[0].Metadata["kind"])
+	assert.Equal(t, fileName, hits[0].Metadata["source"])
+}
+
+// emptyEmbedder returns no embeddings.
+type emptyEmbedder struct{}
+
+func (emptyEmbedder) Embed(ctx context.Context, req llm_client.EmbeddingRequest) (llm_client.EmbeddingResponse, error) {
+	return llm_client.EmbeddingResponse{}, nil
+}
+
+func TestRetrieverIngestKeepsDocs(t *testing.T) {
+	r := &Retriever{Index: NewIndex(""), Embedder: letterEmbedder{}}
+	docs := []Document{{ID: "a", Text: "aaa"}, {ID: "b", Text: "bbb"}}
+	require.NoError(t, r.Ingest(context.Background(), docs...))
+	assert.Nil(t, docs[0].Vector)
+	assert.Nil(t, docs[1].Vector)
+	d, ok := r.Index.Get("b")
+	require.True(t, ok)
+	assert.Equal(t, []float32{0, 3, 0, 0, 0}, d.Vector)
+}
+
+func TestRetrieverEmptyEmbeddings(t *testing.T) {
+	r := &Retriever{Index: NewIndex(""), Embedder: emptyEmbedder{}}
+	_, err := r.Search(context.Background(), "query")
+	assert.ErrorContains(t, err, "no embedding")
+	assert.Error(t, r.Ingest(context.Background(), Document{ID: "a", Text: "text"}))
+	assert.Equal(t, 0, r.Index.Len())
+}